@@ -1,17 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/exklamationmark/notebook/internal/blog"
+	"github.com/exklamationmark/notebook/internal/daemon"
+	"github.com/exklamationmark/notebook/internal/metrics"
+	"github.com/exklamationmark/notebook/internal/middlewares/accesslog"
 	"github.com/exklamationmark/notebook/internal/middlewares/redirect"
+	"github.com/exklamationmark/notebook/internal/middlewares/security"
+	"github.com/exklamationmark/notebook/internal/preview"
+	"github.com/exklamationmark/notebook/internal/site"
 	"github.com/exklamationmark/notebook/internal/staticgen"
 )
 
@@ -30,25 +46,71 @@ func (d *domainsFlag) Set(str string) error {
 }
 
 type config struct {
-	htmlDir string
+	configFile string
+	htmlDir    string
 
 	// 	generate
-	postDir      string
-	postTemplate string
+	postDir     string
+	templateDir string
+	siteTitle   string
+	siteURL     string
+	author      string
+	tagFeeds    bool
+	feedLimit   int
+	robotsFile  string
+	drafts      bool
+	future      bool
+	jobs        int
+	gzip        bool
+	minify      bool
+	aliasPages  bool
+
+	// assets
+	assetDir     string
+	cssFiles     []string
+	jsFiles      []string
+	copiedAssets []string
+
+	// preview
+	previewAddr     string
+	previewDir      string
+	previewInterval time.Duration
 
 	// server
-	adminEmail   string
-	domains      domainsFlag
-	production   bool
-	redirections redirect.Redirections
+	acmeCache       string
+	adminEmail      string
+	domains         domainsFlag
+	production      bool
+	redirections    redirect.Redirections
+	redirectsFile   string
+	redirectsPoll   time.Duration
+	canonicalHost   string
+	shutdownTimeout time.Duration
+	httpAddr        string
+	httpsAddr       string
+	unixSocket      string
+	adminAddr       string
+	cacheControl    map[string]string
+	securityHeaders bool
+	security        security.Policy
+	securityPaths   []security.Override
+	accessLog       string
+	accessFormat    string
+	trustedProxies  []string
+	accessMaxSizeMB int
+	accessRotate    time.Duration
+	accessBackups   int
 }
 
 func main() {
-	var c config
+	c := config{security: security.DefaultPolicy}
 
 	a := kingpin.New(filepath.Base(os.Args[0]), "notebook application")
 	a.HelpFlag.Short('h')
 
+	a.Flag("config", "site config file; flags override its values").Default(defaultConfigFile).
+		StringVar(&c.configFile)
+
 	a.Flag("html.dir", "output html directory").Default("public_html").
 		StringVar(&c.htmlDir)
 
@@ -57,11 +119,99 @@ func main() {
 	gen.Flag("post.dir", "post directory").Default("posts").
 		StringVar(&c.postDir)
 
-	gen.Flag("post.template", "post template file").Default("template.html").
-		StringVar(&c.postTemplate)
+	gen.Flag("template.dir", "directory with layouts/ and partials/ templates").Default("templates").
+		StringVar(&c.templateDir)
+
+	gen.Flag("site.title", "title of the site, required here or as title in the config").Default("").
+		StringVar(&c.siteTitle)
+
+	gen.Flag("site.url", "absolute URL of the site, required here or as url in the config").Default("").
+		StringVar(&c.siteURL)
+
+	gen.Flag("author", "author of posts without one").Default("").
+		StringVar(&c.author)
+
+	gen.Flag("feed.tags", "also generate a feed for each tag").Default("false").
+		BoolVar(&c.tagFeeds)
+
+	gen.Flag("feed.limit", "max. number of entries per feed, 0 for all").Default("0").
+		IntVar(&c.feedLimit)
+
+	gen.Flag("robots.file", "file used as the base of robots.txt").Default("").
+		StringVar(&c.robotsFile)
+
+	gen.Flag("drafts", "include posts marked as draft").Default("false").
+		BoolVar(&c.drafts)
+
+	gen.Flag("future", "include posts published in the future").Default("false").
+		BoolVar(&c.future)
+
+	gen.Flag("jobs", "number of posts to process in parallel").Default(strconv.Itoa(runtime.NumCPU())).
+		IntVar(&c.jobs)
+
+	gen.Flag("compress.gzip", "write a .gz sibling of html, css, js and feeds").Default("true").
+		BoolVar(&c.gzip)
+
+	gen.Flag("minify", "minify generated pages and assets").Default("true").
+		BoolVar(&c.minify)
+
+	gen.Flag("alias.pages", "also generate a page redirecting to the post at each of its aliases, for hosting without serve").Default("false").
+		BoolVar(&c.aliasPages)
+
+	gen.Flag("asset.dir", "asset directory, see the assets command").Default("assets").
+		StringVar(&c.assetDir)
+
+	assetsCmd := a.Command("assets", "bundle, minify and copy assets into html.dir (also done by generate)")
+
+	assetsCmd.Flag("asset.dir", "asset directory").Default("assets").
+		StringVar(&c.assetDir)
+
+	assetsCmd.Flag("minify", "minify the CSS and JS bundles").Default("true").
+		BoolVar(&c.minify)
+
+	assetsCmd.Flag("compress.gzip", "write a .gz sibling of the bundles").Default("true").
+		BoolVar(&c.gzip)
+
+	previewCmd := a.Command("preview", "serve a live-reloading preview while writing posts")
+
+	previewCmd.Flag("post.dir", "post directory").Default("posts").
+		StringVar(&c.postDir)
+
+	previewCmd.Flag("template.dir", "directory with layouts/ and partials/ templates").Default("templates").
+		StringVar(&c.templateDir)
+
+	previewCmd.Flag("jobs", "number of posts to process in parallel").Default(strconv.Itoa(runtime.NumCPU())).
+		IntVar(&c.jobs)
+
+	previewCmd.Flag("asset.dir", "asset directory").Default("assets").
+		StringVar(&c.assetDir)
+
+	previewCmd.Flag("preview.addr", "address to serve the preview on").Default("localhost:8080").
+		StringVar(&c.previewAddr)
+
+	previewCmd.Flag("preview.dir", "directory to build the preview in, with a copy of the pages of html.dir, which is left as is; a temporary one if empty").Default("").
+		StringVar(&c.previewDir)
+
+	previewCmd.Flag("preview.interval", "how often to check for changes").Default("500ms").
+		DurationVar(&c.previewInterval)
+
+	redirects := a.Command("redirects", "manage redirections")
+	check := redirects.Command("check", "find redirect loops, chains, duplicates and redirections to missing pages in html.dir")
+
+	check.Flag("domains", "domains served").Default("example.com").
+		SetValue(&c.domains)
+
+	check.Flag("redirect", "comma-separated from=>to or from=>to=>status redirections").Default("").
+		SetValue(&c.redirections)
+
+	check.Flag("redirects.file", "file with redirections, see serve").Default("").
+		StringVar(&c.redirectsFile)
 
 	server := a.Command("serve", "run blog server")
 
+	server.Flag("acme.cache", "directory to store Let's Encrypt keys and certificates in, outside of html.dir").Default("acme-cache").
+		StringVar(&c.acmeCache)
+
 	server.Flag("admin.email", "admin email for Let's Encrypt").Default("admin@example.com").
 		StringVar(&c.adminEmail)
 
@@ -71,9 +221,57 @@ func main() {
 	server.Flag("production", "production mode (enable HTTPS)").Default("false").
 		BoolVar(&c.production)
 
-	server.Flag("redirect", "redirection options").Default("").
+	server.Flag("redirect", "comma-separated from=>to or from=>to=>status (301, 302, 307 or 308) redirections; from can be a pattern, see server.redirects in the config").Default("").
 		SetValue(&c.redirections)
 
+	server.Flag("redirects.file", "file with redirections, YAML (.yaml, .yml) or \"from to [status]\" lines as in Netlify's _redirects; reloaded on change or SIGHUP").Default("").
+		StringVar(&c.redirectsFile)
+
+	server.Flag("redirects.interval", "how often to check the redirects file for changes").Default("2s").
+		DurationVar(&c.redirectsPoll)
+
+	server.Flag("canonical.host", "host to permanently redirect the other domains to, e.g example.com; pages are always redirected to their URL without .html or trailing slash").Default("").
+		StringVar(&c.canonicalHost)
+
+	server.Flag("shutdown.timeout", "how long to wait for in-flight requests on SIGTERM/SIGINT").Default("10s").
+		DurationVar(&c.shutdownTimeout)
+
+	server.Flag("http.addr", "address to serve HTTP on (blog in dev, ACME challenges and redirect to HTTPS in production): host:port, unix:/path or systemd:name for socket activation").Default(":80").
+		StringVar(&c.httpAddr)
+
+	server.Flag("https.addr", "address to serve HTTPS on in production: host:port, unix:/path or systemd:name for socket activation").Default(":443").
+		StringVar(&c.httpsAddr)
+
+	server.Flag("cache.control", "Cache-Control header by extension, e.g .html=no-cache (repeatable); fingerprinted assets are cached forever").
+		StringMapVar(&c.cacheControl)
+
+	server.Flag("security.headers", "send security headers (CSP, HSTS in production, ...), see server.security in the config; only X-Content-Type-Options if false").Default("true").
+		BoolVar(&c.securityHeaders)
+
+	server.Flag("unix.socket", "also serve the blog over plain HTTP on this Unix socket, e.g for a reverse proxy").Default("").
+		StringVar(&c.unixSocket)
+
+	server.Flag("admin.addr", "address to serve /metrics (Prometheus) on, e.g localhost:9100: host:port, unix:/path or systemd:name; none if empty").Default("").
+		StringVar(&c.adminAddr)
+
+	server.Flag("access.log", "file to write the access log to, - for stdout; no access log if empty").Default("").
+		StringVar(&c.accessLog)
+
+	server.Flag("access.format", "access log format: common, combined or json").Default("combined").
+		StringVar(&c.accessFormat)
+
+	server.Flag("access.trusted_proxy", "IP or CIDR of a reverse proxy whose X-Forwarded-For is logged as the client address (repeatable)").
+		StringsVar(&c.trustedProxies)
+
+	server.Flag("access.max_size_mb", "rotate the access log file when it would grow bigger than this, 0 to disable").Default("100").
+		IntVar(&c.accessMaxSizeMB)
+
+	server.Flag("access.rotate_every", "rotate the access log file when it gets older than this, e.g 24h; 0 to disable").Default("0").
+		DurationVar(&c.accessRotate)
+
+	server.Flag("access.max_backups", "number of rotated access log files to keep, 0 for all").Default("7").
+		IntVar(&c.accessBackups)
+
 	// ----------------------------------------
 
 	cmd, err := a.Parse(os.Args[1:])
@@ -84,59 +282,514 @@ func main() {
 	}
 	fmt.Printf("%#v\n", cmd)
 
+	set, err := flagsSetByUser(a, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error parsing commandline arguments"))
+		os.Exit(2)
+	}
+	if err := c.loadSiteConfig(cmd, set); err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error loading site config"))
+		os.Exit(2)
+	}
+
 	switch cmd {
+	case "assets":
+		if err := generateAssets(c); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error generating assets"))
+			os.Exit(1)
+		}
 	case "generate":
-		if err := staticgen.Generate(c.postDir, c.postTemplate, c.htmlDir); err != nil {
+		if len(c.siteTitle) < 1 {
+			fmt.Fprintf(os.Stderr, "Invalid site config: no title, set title in %s or --site.title\n", c.configFile)
+			os.Exit(2)
+		}
+		// first, as pages link to the fingerprinted assets
+		if err := generateAssets(c); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error generating assets"))
+			os.Exit(1)
+		}
+		if err := staticgen.Generate(c.postDir, c.templateDir, c.htmlDir,
+			staticgen.Title(c.siteTitle),
+			staticgen.BaseURL(c.siteURL),
+			staticgen.Author(c.author),
+			staticgen.TagFeeds(c.tagFeeds),
+			staticgen.FeedLimit(c.feedLimit),
+			staticgen.RobotsFile(c.robotsFile),
+			staticgen.Drafts(c.drafts),
+			staticgen.Future(c.future),
+			staticgen.Jobs(c.jobs),
+			staticgen.Gzip(c.gzip),
+			staticgen.Minify(c.minify),
+			staticgen.AliasPages(c.aliasPages),
+		); err != nil {
 			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error generating html"))
 			os.Exit(1)
 		}
+	case "preview":
+		if err := runPreview(c); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Preview server failed"))
+			os.Exit(1)
+		}
+	case "redirects check":
+		problems, err := checkRedirects(c)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Invalid redirects"))
+			os.Exit(1)
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
 	case "serve":
 		fmt.Printf("%#v\n", c)
-		srv, err := blog.New(c.htmlDir, c.adminEmail, c.domains, blog.Redirect(c.redirections))
+		cache, err := blog.NewACMEDirCache(c.acmeCache, c.htmlDir, c.domains)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Cannot prepare ACME cache"))
+			os.Exit(1)
+		}
+
+		accessLog, closeAccessLog, err := openAccessLog(c)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Cannot open access log"))
+			os.Exit(1)
+		}
+		defer closeAccessLog()
+
+		reg := metrics.NewRegistry()
+		policy, overrides := c.securityPolicy()
+		srv, err := blog.New(c.htmlDir, c.adminEmail, c.domains,
+			blog.AliasesFile(filepath.Join(c.htmlDir, staticgen.AliasesFile)),
+			blog.Redirect(c.redirections),
+			blog.RedirectsFile(c.redirectsFile),
+			blog.Canonical(c.canonicalHost),
+			blog.ACMECache(cache),
+			blog.CacheControl(c.cacheControl),
+			blog.Security(policy, overrides...),
+			blog.AccessLog(accessLog, accesslog.Format(c.accessFormat), c.trustedProxies...),
+			blog.Metrics(reg),
+		)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Cannot create server"))
 			os.Exit(1)
 		}
 
-		if !c.production {
-			runInDev(srv)
-			return
+		srv.WarnRedirectProblems()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+		go func() {
+			<-stop
+			signal.Stop(stop) // a second signal kills the process
+			cancel()
+		}()
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go srv.WatchRedirects(ctx, c.redirectsPoll, hup)
+
+		run := runInDev
+		if c.production {
+			run = runInProd
+		}
+		if err := run(ctx, c, srv, reg); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Blog server failed"))
+			os.Exit(1)
 		}
-		runInProd(srv)
 	}
 }
 
-func runInProd(srv *blog.Server) {
-	go func() {
-		httpSrv := &http.Server{
-			Addr:    ":80",
-			Handler: srv.HTTPRedirectHandler(),
+const defaultConfigFile = "notebook.yaml"
+
+// flagsSetByUser returns the names of the flags given on the command line,
+// as opposed to the ones left to their default.
+func flagsSetByUser(a *kingpin.Application, args []string) (map[string]bool, error) {
+	ctx, err := a.ParseContext(args)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool)
+	for _, el := range ctx.Elements {
+		if flag, ok := el.Clause.(*kingpin.FlagClause); ok {
+			set[flag.Model().Name] = true
 		}
-		if err := httpSrv.ListenAndServe(); err != nil {
-			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "HTTP server failed"))
-			os.Exit(1)
+	}
+
+	return set, nil
+}
+
+// loadSiteConfig fills c with the values from the site config file, then checks
+// what cmd needs: generate can only write feeds and sitemap with an absolute URL.
+func (c *config) loadSiteConfig(cmd string, set map[string]bool) error {
+	if err := c.readSiteConfig(set); err != nil {
+		return err
+	}
+	if cmd != "generate" {
+		return nil
+	}
+
+	u, err := url.Parse(c.siteURL)
+	if err != nil || !u.IsAbs() || len(u.Host) < 1 {
+		return errors.Errorf("invalid site URL %q, set an absolute URL as url in %s or --site.url", c.siteURL, c.configFile)
+	}
+
+	return nil
+}
+
+// readSiteConfig fills c with the values from the site config file,
+// except for the flags set on the command line, which take precedence.
+// A missing default config file is not an error.
+func (c *config) readSiteConfig(set map[string]bool) error {
+	if _, err := os.Stat(c.configFile); os.IsNotExist(err) && !set["config"] {
+		return nil
+	}
+
+	s, err := site.Load(c.configFile)
+	if err != nil {
+		return err
+	}
+
+	str := func(flag, val string, dst *string) {
+		if !set[flag] && len(val) > 0 {
+			*dst = val
 		}
+	}
+	str("html.dir", s.Dirs.HTML, &c.htmlDir)
+	str("post.dir", s.Dirs.Posts, &c.postDir)
+	str("template.dir", s.Dirs.Templates, &c.templateDir)
+	str("asset.dir", s.Dirs.Assets, &c.assetDir)
+	str("acme.cache", s.Dirs.ACMECache, &c.acmeCache)
+	str("site.title", s.Title, &c.siteTitle)
+	str("site.url", s.URL, &c.siteURL)
+	str("author", s.Author, &c.author)
+	str("admin.email", s.Server.AdminEmail, &c.adminEmail)
+	str("http.addr", s.Server.HTTPAddr, &c.httpAddr)
+	str("https.addr", s.Server.HTTPSAddr, &c.httpsAddr)
+	str("unix.socket", s.Server.UnixSocket, &c.unixSocket)
+	str("admin.addr", s.Server.AdminAddr, &c.adminAddr)
+	str("redirects.file", s.Server.RedirectsFile, &c.redirectsFile)
+	str("canonical.host", s.Server.CanonicalHost, &c.canonicalHost)
+	str("access.log", s.Server.AccessLog.File, &c.accessLog)
+	str("access.format", s.Server.AccessLog.Format, &c.accessFormat)
+
+	if !set["feed.tags"] && s.Feed.Tags {
+		c.tagFeeds = true
+	}
+	if !set["feed.limit"] && s.Feed.Limit > 0 {
+		c.feedLimit = s.Feed.Limit
+	}
+	if !set["domains"] && len(s.Server.Domains) > 0 {
+		c.domains = s.Server.Domains
+	}
+	if len(s.Assets.CSS) > 0 {
+		c.cssFiles = s.Assets.CSS
+	}
+	if len(s.Assets.JS) > 0 {
+		c.jsFiles = s.Assets.JS
+	}
+	if len(s.Assets.Copy) > 0 {
+		c.copiedAssets = s.Assets.Copy
+	}
+	if !set["access.trusted_proxy"] && len(s.Server.AccessLog.TrustedProxies) > 0 {
+		c.trustedProxies = s.Server.AccessLog.TrustedProxies
+	}
+	if !set["access.max_size_mb"] && s.Server.AccessLog.MaxSizeMB > 0 {
+		c.accessMaxSizeMB = s.Server.AccessLog.MaxSizeMB
+	}
+	if !set["access.rotate_every"] && s.Server.AccessLog.RotateEvery > 0 {
+		c.accessRotate = s.Server.AccessLog.RotateEvery
+	}
+	if !set["access.max_backups"] && s.Server.AccessLog.MaxBackups > 0 {
+		c.accessBackups = s.Server.AccessLog.MaxBackups
+	}
+	c.security, c.securityPaths = s.SecurityPolicy()
+	if !set["cache.control"] && len(s.Server.CacheControl) > 0 {
+		c.cacheControl = s.Server.CacheControl
+	}
+	if !set["redirect"] && len(s.Server.Redirects) > 0 {
+		rds, err := s.Redirections()
+		if err != nil {
+			return err
+		}
+		c.redirections = rds
+	}
+
+	return nil
+}
+
+func generateAssets(c config) error {
+	return staticgen.GenerateAssets(c.assetDir, c.htmlDir,
+		staticgen.CSSFiles(c.cssFiles),
+		staticgen.JSFiles(c.jsFiles),
+		staticgen.CopyAssets(c.copiedAssets),
+		staticgen.Minify(c.minify),
+		staticgen.Gzip(c.gzip),
+	)
+}
+
+// securityPolicy returns the security headers to send: none without --security.headers,
+// and no HSTS outside of production, as it would stick to the browsers of developers.
+func (c config) securityPolicy() (security.Policy, []security.Override) {
+	if !c.securityHeaders {
+		return security.Policy{}, nil
+	}
+	if c.production {
+		return c.security, c.securityPaths
+	}
+
+	p := c.security
+	p.HSTSMaxAge = 0
+	overrides := make([]security.Override, 0, len(c.securityPaths))
+	for _, o := range c.securityPaths {
+		o.HSTSMaxAge = 0
+		overrides = append(overrides, o)
+	}
+
+	return p, overrides
+}
+
+func checkRedirects(c config) ([]redirect.Problem, error) {
+	srv, err := blog.New(c.htmlDir, "", c.domains,
+		blog.AliasesFile(filepath.Join(c.htmlDir, staticgen.AliasesFile)),
+		blog.Redirect(c.redirections),
+		blog.RedirectsFile(c.redirectsFile),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return srv.CheckRedirects()
+}
+
+// openAccessLog returns where to write the access log, nil if there is none,
+// and a function to close it.
+func openAccessLog(c config) (io.Writer, func() error, error) {
+	switch c.accessLog {
+	case "":
+		return nil, func() error { return nil }, nil
+	case "-":
+		return os.Stdout, func() error { return nil }, nil
+	}
+
+	f, err := accesslog.OpenRotatingFile(c.accessLog, accesslog.Rotation{
+		MaxSize:    int64(c.accessMaxSizeMB) << 20,
+		Interval:   c.accessRotate,
+		MaxBackups: c.accessBackups,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, f.Close, nil
+}
+
+// runPreview builds the site, with drafts and future posts, in a directory of its own,
+// as it must not end up in html.dir, and serves it until SIGTERM/SIGINT.
+func runPreview(c config) error {
+	host, _, err := net.SplitHostPort(c.previewAddr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid preview address %q", c.previewAddr)
+	}
+
+	dir := c.previewDir
+	if len(dir) < 1 {
+		dir, err = ioutil.TempDir(os.TempDir(), "notebook-preview")
+		if err != nil {
+			return errors.Wrapf(err, "cannot create preview directory")
+		}
+		defer os.RemoveAll(dir)
+	}
+	if err := copyPages(c.htmlDir, dir); err != nil {
+		return err
+	}
+	c.htmlDir = dir
+
+	srv, err := blog.New(c.htmlDir, "", []string{host})
+	if err != nil {
+		return errors.Wrapf(err, "cannot create blog server")
+	}
+
+	posts := preview.Step{
+		Name: "posts",
+		// pages link to the fingerprinted assets, so they are re-rendered when assets change
+		Paths: []string{c.postDir, c.templateDir, c.assetDir},
+		Run: func() error {
+			return staticgen.Generate(c.postDir, c.templateDir, c.htmlDir,
+				staticgen.Title(c.siteTitle),
+				staticgen.BaseURL("http://"+c.previewAddr),
+				staticgen.Author(c.author),
+				staticgen.TagFeeds(c.tagFeeds),
+				staticgen.FeedLimit(c.feedLimit),
+				staticgen.Drafts(true),
+				staticgen.Future(true),
+				staticgen.Jobs(c.jobs),
+				staticgen.Gzip(false), // served uncompressed to inject the reload script
+				staticgen.Minify(false),
+			)
+		},
+	}
+	assets := preview.Step{
+		Name:  "assets",
+		Paths: []string{c.assetDir},
+		Run: func() error {
+			c := c
+			c.gzip, c.minify = false, false
+			return generateAssets(c)
+		},
+	}
+
+	previewSrv := preview.New(srv.BlogHandler(), assets, posts)
+	if err := previewSrv.Build(); err != nil {
+		return errors.Wrapf(err, "cannot build site")
+	}
+	go previewSrv.Watch(c.previewInterval, make(chan struct{}))
+
+	httpSrv := &http.Server{Addr: c.previewAddr, Handler: previewSrv.Handler()}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-stop
+		signal.Stop(stop)
+		httpSrv.Close()
 	}()
 
-	blogSrv := &http.Server{
-		Addr:      ":443",
-		Handler:   srv.BlogHandler(),
-		TLSConfig: srv.TLSConfig(),
+	fmt.Printf("previewing %s on http://%s\n", c.htmlDir, c.previewAddr)
+	if err := httpSrv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// copyPages copies the files of src into dst, e.g hand-written pages and favicons,
+// except hidden files (the build manifest) and precompressed siblings, which would
+// be served instead of the preview.
+func copyPages(src, dst string) error {
+	err := filepath.Walk(src, func(fname string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, fname)
+		if err != nil {
+			return err
+		}
+		if rel != "." && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(dst, rel)
+		switch ext := filepath.Ext(fname); {
+		case info.IsDir():
+			return os.MkdirAll(target, 0776)
+		case ext == ".gz" || ext == ".br":
+			return nil
+		}
+		b, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, b, 0664)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return errors.Wrapf(err, "cannot copy %q to %q", src, dst)
+}
+
+// listen opens a listener for each address, closing them all if any fails.
+func listen(addrs ...string) ([]net.Listener, error) {
+	lns := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		ln, err := daemon.Listen(addr)
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+
+	return lns, nil
+}
+
+// extraServers serves the blog over plain HTTP on the Unix socket, e.g for a reverse proxy,
+// and the metrics on the admin address, when they are set.
+func extraServers(c config, srv *blog.Server, reg *metrics.Registry) ([]daemon.Server, error) {
+	var addrs []string
+	var handlers []http.Handler
+	if len(c.unixSocket) > 0 {
+		addrs = append(addrs, "unix:"+c.unixSocket)
+		handlers = append(handlers, srv.BlogHandler())
+	}
+	if len(c.adminAddr) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", reg.Handler())
+		addrs = append(addrs, c.adminAddr)
+		handlers = append(handlers, mux)
+	}
+
+	lns, err := listen(addrs...)
+	if err != nil {
+		return nil, err
+	}
+	srvs := make([]daemon.Server, 0, len(lns))
+	for i, ln := range lns {
+		srvs = append(srvs, daemon.Server{Listener: ln, Server: &http.Server{Handler: handlers[i]}})
+	}
+
+	return srvs, nil
+}
+
+func runInProd(ctx context.Context, c config, srv *blog.Server, reg *metrics.Registry) error {
+	lns, err := listen(c.httpAddr, c.httpsAddr)
+	if err != nil {
+		return err
 	}
-	if err := blogSrv.ListenAndServeTLS("", ""); err != nil {
-		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Blog server failed"))
-		os.Exit(1)
+	srvs := []daemon.Server{
+		{Listener: lns[0], Server: &http.Server{
+			Handler: srv.HTTPRedirectHandler(),
+		}},
+		{Listener: lns[1], Server: &http.Server{
+			Handler:   srv.BlogHandler(),
+			TLSConfig: srv.TLSConfig(),
+		}},
 	}
+
+	extra, err := extraServers(c, srv, reg)
+	if err != nil {
+		lns[0].Close()
+		lns[1].Close()
+		return err
+	}
+
+	return daemon.Run(ctx, c.shutdownTimeout, append(srvs, extra...)...)
 }
 
-func runInDev(srv *blog.Server) {
-	blogSrv := &http.Server{
-		Addr:    ":80",
-		Handler: srv.BlogHandler(),
+func runInDev(ctx context.Context, c config, srv *blog.Server, reg *metrics.Registry) error {
+	lns, err := listen(c.httpAddr)
+	if err != nil {
+		return err
 	}
-	if err := blogSrv.ListenAndServe(); err != nil {
-		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "blog server failed"))
-		os.Exit(1)
+	srvs := []daemon.Server{
+		{Listener: lns[0], Server: &http.Server{
+			Handler: srv.BlogHandler(),
+		}},
+	}
+
+	extra, err := extraServers(c, srv, reg)
+	if err != nil {
+		lns[0].Close()
+		return err
 	}
 
+	return daemon.Run(ctx, c.shutdownTimeout, append(srvs, extra...)...)
 }
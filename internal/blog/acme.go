@@ -0,0 +1,94 @@
+package blog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/exklamationmark/glog"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ACMECache sets where the Let's Encrypt account key and certificates are stored.
+// Without it, they are not cached and are requested again on every restart.
+func ACMECache(cache autocert.Cache) func(*config) {
+	return func(c *config) {
+		c.acmeCache = cache
+	}
+}
+
+// NewACMEDirCache prepares dir to store ACME secrets: it must not overlap with
+// htmlDir (or its content could be served), and is only accessible by the owner.
+// Account keys and certificates for domains cached in htmlDir by older versions
+// are moved to dir.
+func NewACMEDirCache(dir, htmlDir string, domains []string) (autocert.Cache, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot find absolute path to %q", dir)
+	}
+	absHTMLDir, err := filepath.Abs(htmlDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot find absolute path to %q", htmlDir)
+	}
+	if isWithin(absDir, absHTMLDir) || isWithin(absHTMLDir, absDir) {
+		return nil, errors.Errorf("ACME cache %q must not overlap with html directory %q", absDir, absHTMLDir)
+	}
+
+	if err := os.MkdirAll(absDir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "cannot create ACME cache %q", absDir)
+	}
+	if err := os.Chmod(absDir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "cannot restrict permissions of ACME cache %q", absDir)
+	}
+
+	if err := migrateACMECache(absHTMLDir, absDir, domains); err != nil {
+		return nil, errors.Wrapf(err, "cannot migrate ACME cache from %q", absHTMLDir)
+	}
+
+	return autocert.DirCache(absDir), nil
+}
+
+// isWithin returns true if path is dir or inside of it.
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// acmeCacheKeys lists the names autocert.DirCache uses for domains.
+func acmeCacheKeys(domains []string) []string {
+	keys := []string{"acme_account+key", "acme_account.key"}
+	for _, d := range domains {
+		keys = append(keys, d, d+"+rsa", d+"+token")
+	}
+
+	return keys
+}
+
+func migrateACMECache(from, to string, domains []string) error {
+	for _, key := range acmeCacheKeys(domains) {
+		src, dst := filepath.Join(from, key), filepath.Join(to, key)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if _, err := os.Stat(dst); err == nil {
+			glog.Warningf("%q is already in the ACME cache, please delete %q", key, src)
+			continue
+		}
+
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read %q", src)
+		}
+		if err := ioutil.WriteFile(dst, b, 0600); err != nil {
+			return errors.Wrapf(err, "cannot write %q", dst)
+		}
+		if err := os.Remove(src); err != nil {
+			return errors.Wrapf(err, "cannot remove %q", src)
+		}
+
+		glog.V(0).Infof("moved %q to the ACME cache %q", src, to)
+	}
+
+	return nil
+}
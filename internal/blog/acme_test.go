@@ -0,0 +1,93 @@
+package blog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewACMEDirCacheOverlap(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "blog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", root, err)
+	}
+	defer os.RemoveAll(root)
+
+	var testCases = []struct {
+		name    string
+		dir     string
+		htmlDir string
+	}{
+		{"same", root + "/html", root + "/html"},
+		{"inside html", root + "/html/acme", root + "/html"},
+		{"html inside", root + "/acme", root + "/acme/html"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewACMEDirCache(tc.dir, tc.htmlDir, exampleDomains)
+			if err == nil || !strings.Contains(err.Error(), "must not overlap") {
+				t.Errorf("want overlap error, got= %v", err)
+			}
+		})
+	}
+}
+
+func TestNewACMEDirCache(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "blog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", root, err)
+	}
+	defer os.RemoveAll(root)
+
+	htmlDir, dir := root+"/html", root+"/acme"
+	if err := os.MkdirAll(htmlDir, 0775); err != nil {
+		t.Fatalf("cannot create html dir, err= %v", err)
+	}
+	old := map[string]string{
+		"acme_account+key": "account key",
+		"example.com":      "cert",
+		"example.com+rsa":  "rsa cert",
+		"index.html":       "<p>index</p>",
+	}
+	for name, content := range old {
+		if err := ioutil.WriteFile(filepath.Join(htmlDir, name), []byte(content), 0664); err != nil {
+			t.Fatalf("cannot write %s, err= %v", name, err)
+		}
+	}
+
+	if _, err := NewACMEDirCache(dir, htmlDir, exampleDomains); err != nil {
+		t.Fatalf("want NewACMEDirCache() to return no error, got= %v", err)
+	}
+
+	stat, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("want cache dir to be created, err= %v", err)
+	}
+	if want, got := os.FileMode(0700), stat.Mode().Perm(); want != got {
+		t.Errorf("wrong cache dir permissions, want= %v, got= %v", want, got)
+	}
+
+	for _, name := range []string{"acme_account+key", "example.com", "example.com+rsa"} {
+		if _, err := os.Stat(filepath.Join(htmlDir, name)); !os.IsNotExist(err) {
+			t.Errorf("want %s to be moved out of html dir, err= %v", name, err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("want %s to be moved to the cache, err= %v", name, err)
+			continue
+		}
+		if want, got := old[name], string(b); want != got {
+			t.Errorf("wrong content for %s, want= %q, got= %q", name, want, got)
+		}
+		stat, _ := os.Stat(filepath.Join(dir, name))
+		if want, got := os.FileMode(0600), stat.Mode().Perm(); want != got {
+			t.Errorf("wrong permissions for %s, want= %v, got= %v", name, want, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(htmlDir, "index.html")); err != nil {
+		t.Errorf("want other files to be left alone, err= %v", err)
+	}
+}
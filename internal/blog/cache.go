@@ -0,0 +1,85 @@
+package blog
+
+import (
+	"net/http"
+	"path/filepath"
+	"regexp"
+)
+
+// CacheRules maps file extensions (e.g .html) to the Cache-Control header
+// of the files served with them. The rule for "" applies to other extensions.
+type CacheRules map[string]string
+
+// DefaultCacheRules keep pages and feeds fresh, as they change on every post.
+var DefaultCacheRules = CacheRules{
+	".html": "public, max-age=300",
+	".xml":  "public, max-age=3600",
+	".atom": "public, max-age=3600",
+	".rss":  "public, max-age=3600",
+	".txt":  "public, max-age=3600",
+	"":      "public, max-age=86400",
+}
+
+// immutableCacheControl is sent for fingerprinted files, whose name changes with their content.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// fingerprinted matches the names staticgen gives assets, e.g builtin.0123456789.css.
+var fingerprinted = regexp.MustCompile(`\.[0-9a-f]{10}\.[[:alnum:]]+$`)
+
+// CacheControl overrides some of the DefaultCacheRules.
+func CacheControl(rules CacheRules) func(*config) {
+	return func(c *config) {
+		for ext, value := range rules {
+			c.cacheRules[ext] = value
+		}
+	}
+}
+
+func (rules CacheRules) forFile(fname string) string {
+	if fingerprinted.MatchString(fname) {
+		return immutableCacheControl
+	}
+	if value, exist := rules[filepath.Ext(fname)]; exist {
+		return value
+	}
+
+	return rules[""]
+}
+
+// cacheControl sets the Cache-Control header of the files served by next.
+// Error pages are left alone.
+func cacheControl(next http.Handler, rules CacheRules) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := rules.forFile(fileToServe("", r.URL.Path))
+		next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, value: value}, r)
+	})
+}
+
+type cacheControlWriter struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	switch status {
+	case http.StatusOK, http.StatusPartialContent, http.StatusNotModified:
+		if len(w.value) > 0 {
+			w.Header().Set("Cache-Control", w.value)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
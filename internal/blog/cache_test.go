@@ -0,0 +1,80 @@
+package blog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheRulesForFile(t *testing.T) {
+	rules := CacheRules{
+		".html": "no-cache",
+		"":      "max-age=60",
+	}
+
+	var testCases = []struct {
+		fname    string
+		expected string
+	}{
+		{"/index.html", "no-cache"},
+		{"/2018/07/21/post.html", "no-cache"},
+		{"/builtin.css", "max-age=60"},
+		{"/builtin.0123456789.css", immutableCacheControl},
+		{"/builtin.0123456789.js", immutableCacheControl},
+		{"/builtin.0123.css", "max-age=60"},
+	}
+
+	for _, tc := range testCases {
+		if want, got := tc.expected, rules.forFile(tc.fname); want != got {
+			t.Errorf("wrong Cache-Control for %q, want= %q, got= %q", tc.fname, want, got)
+		}
+	}
+}
+
+func TestBlogHandlerCacheControl(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+
+	srv, err := New("testdata", "admin@example.com", exampleDomains,
+		CacheControl(CacheRules{".css": "public, max-age=600"}),
+	)
+	if err != nil {
+		t.Fatalf("want New() to return no error, got= %v", err)
+	}
+
+	var testCases = []struct {
+		path                 string
+		ifModifiedSince      string
+		expectedStatus       int
+		expectedCacheControl string
+	}{
+		{"/sample", "", http.StatusOK, DefaultCacheRules[".html"]},
+		{"/", "", http.StatusOK, DefaultCacheRules[".html"]},
+		{"/feed.atom", "", http.StatusOK, DefaultCacheRules[".atom"]},
+		{"/style.css", "", http.StatusOK, "public, max-age=600"},
+		{"/builtin.0123456789.css", "", http.StatusOK, immutableCacheControl},
+		{"/favicon.ico", "", http.StatusOK, DefaultCacheRules[""]},
+		{"/sample", "Mon, 02 Jan 2100 15:04:05 GMT", http.StatusNotModified, DefaultCacheRules[".html"]},
+		{"/non-existing", "", http.StatusNotFound, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://example.com"+tc.path, nil)
+			if len(tc.ifModifiedSince) > 0 {
+				req.Header.Set("If-Modified-Since", tc.ifModifiedSince)
+			}
+			w := httptest.NewRecorder()
+
+			srv.BlogHandler().ServeHTTP(w, req)
+			resp := w.Result()
+			if want, got := tc.expectedStatus, resp.StatusCode; want != got {
+				t.Errorf("wrote wrong HTTP status, want= %v, got= %v", want, got)
+			}
+			if want, got := tc.expectedCacheControl, resp.Header.Get("Cache-Control"); want != got {
+				t.Errorf("wrote wrong Cache-Control, want= %q, got= %q", want, got)
+			}
+		})
+	}
+}
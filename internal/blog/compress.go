@@ -0,0 +1,169 @@
+package blog
+
+import (
+	"compress/gzip"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// compressibleExts are the extensions of files worth compressing;
+// images and fonts already are.
+var compressibleExts = map[string]bool{
+	".html": true,
+	".css":  true,
+	".js":   true,
+	".xml":  true,
+	".atom": true,
+	".rss":  true,
+	".txt":  true,
+	".svg":  true,
+}
+
+// encodings are the precompressed siblings looked for, by order of preference.
+var encodings = []struct {
+	name string
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// acceptedEncodings parses an Accept-Encoding header into the q-value of each coding.
+// Codings with q=0 are refused.
+func acceptedEncodings(header string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if len(coding) == 0 {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[coding] = q
+	}
+
+	return accepted
+}
+
+func acceptQ(accepted map[string]float64, coding string) float64 {
+	if q, exist := accepted[coding]; exist {
+		return q
+	}
+
+	return accepted["*"]
+}
+
+// precompressed returns the best sibling of fname (e.g fname.br, fname.gz) the client accepts,
+// with its encoding. Siblings older than fname are ignored, as they could be stale.
+func precompressed(r *http.Request, fname string, stat os.FileInfo) (string, os.FileInfo, string) {
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+
+	var best, bestEncoding string
+	var bestStat os.FileInfo
+	bestQ := 0.0
+	for _, enc := range encodings {
+		q := acceptQ(accepted, enc.name)
+		if q <= bestQ {
+			continue
+		}
+
+		sibling := fname + enc.ext
+		siblingStat, err := os.Stat(sibling)
+		if err != nil || siblingStat.ModTime().Before(stat.ModTime()) {
+			continue
+		}
+		best, bestStat, bestEncoding, bestQ = sibling, siblingStat, enc.name, q
+	}
+
+	return best, bestStat, bestEncoding
+}
+
+// acceptsGzip returns true if the client accepts gzip-encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	return acceptQ(acceptedEncodings(r.Header.Get("Accept-Encoding")), "gzip") > 0
+}
+
+// serveCompressed serves fname compressed, from a precompressed sibling if there's one,
+// or gzipped on the fly otherwise. It returns false if the client accepts no compression
+// or fname isn't worth compressing.
+func serveCompressed(w http.ResponseWriter, r *http.Request, fname string, stat os.FileInfo) bool {
+	if !compressibleExts[filepath.Ext(fname)] {
+		return false
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if sibling, siblingStat, encoding := precompressed(r, fname, stat); len(sibling) > 0 {
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Set("ETag", etag(siblingStat))
+		serveFile(w, r, sibling)
+		return true
+	}
+
+	if !acceptsGzip(r) {
+		return false
+	}
+
+	// the compressed length is unknown, so ranges can't be served
+	r.Header.Del("Range")
+	gw := &gzipResponseWriter{ResponseWriter: w, head: r.Method == http.MethodHead}
+	defer gw.Close()
+	w.Header().Set("ETag", strings.TrimSuffix(etag(stat), `"`)+`-gzip"`)
+	serveFile(gw, r, fname)
+
+	return true
+}
+
+// gzipResponseWriter gzips the body of successful responses.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	head        bool
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if status == http.StatusOK {
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Encoding", "gzip")
+		if !w.head {
+			w.gz = gzip.NewWriter(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.gz.Write(b)
+}
+
+func (w *gzipResponseWriter) Close() error {
+	if w.gz == nil {
+		return nil
+	}
+
+	return w.gz.Close()
+}
@@ -0,0 +1,175 @@
+package blog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAcceptedEncodings(t *testing.T) {
+	var testCases = []struct {
+		header   string
+		expected map[string]float64
+	}{
+		{"", map[string]float64{}},
+		{"gzip", map[string]float64{"gzip": 1}},
+		{"gzip, deflate, br", map[string]float64{"gzip": 1, "deflate": 1, "br": 1}},
+		{"br;q=0.5, GZIP;q=0.8", map[string]float64{"br": 0.5, "gzip": 0.8}},
+		{"*;q=0.1, identity", map[string]float64{"*": 0.1, "identity": 1}},
+		{"gzip;q=0", map[string]float64{"gzip": 0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			if want, got := tc.expected, acceptedEncodings(tc.header); !reflect.DeepEqual(want, got) {
+				t.Errorf("wrong encodings, want= %v, got= %v", want, got)
+			}
+		})
+	}
+}
+
+func gzipBytes(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	if err := w.Close(); err != nil {
+		t.Fatalf("cannot gzip %q, err= %v", s, err)
+	}
+
+	return buf.Bytes()
+}
+
+func gunzipBytes(t *testing.T, b []byte) string {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("body is not gzipped, err= %v", err)
+	}
+	plain, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("cannot decompress body, err= %v", err)
+	}
+
+	return string(plain)
+}
+
+func TestBlogHandlerCompression(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+
+	dir, err := ioutil.TempDir(os.TempDir(), "blog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+
+	page, style := "<p>page</p>", "a{}"
+	files := map[string][]byte{
+		"page.html":     []byte(page),
+		"page.html.gz":  gzipBytes(t, page),
+		"page.html.br":  []byte("brotli page"), // not real brotli, served as is
+		"stale.html":    []byte(page),
+		"stale.html.gz": gzipBytes(t, "<p>old</p>"),
+		"style.css":     []byte(style),
+		"logo.png":      []byte("\x89PNG"),
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(dir+"/"+name, content, 0664); err != nil {
+			t.Fatalf("cannot write %s, err= %v", name, err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(dir+"/stale.html.gz", old, old)
+
+	var testCases = []struct {
+		name             string
+		method           string
+		path             string
+		acceptEncoding   string
+		expectedEncoding string
+		expectedVary     string
+		expectedType     string
+		expectedBody     string // decompressed
+	}{
+		{"identity", http.MethodGet, "/page", "", "", "Accept-Encoding", "text/html; charset=utf-8", page},
+		{"precompressed gzip", http.MethodGet, "/page", "gzip", "gzip", "Accept-Encoding", "text/html; charset=utf-8", page},
+		{"precompressed brotli", http.MethodGet, "/page", "gzip, br", "br", "Accept-Encoding", "text/html; charset=utf-8", "brotli page"},
+		{"brotli refused", http.MethodGet, "/page", "br;q=0, gzip", "gzip", "Accept-Encoding", "text/html; charset=utf-8", page},
+		{"gzip preferred", http.MethodGet, "/page", "br;q=0.2, gzip;q=0.8", "gzip", "Accept-Encoding", "text/html; charset=utf-8", page},
+		{"stale sibling", http.MethodGet, "/stale", "gzip", "gzip", "Accept-Encoding", "text/html; charset=utf-8", page},
+		{"on the fly", http.MethodGet, "/style.css", "gzip", "gzip", "Accept-Encoding", "text/css; charset=utf-8", style},
+		{"on the fly HEAD", http.MethodHead, "/style.css", "gzip", "gzip", "Accept-Encoding", "text/css; charset=utf-8", ""},
+		{"not compressible", http.MethodGet, "/logo.png", "gzip", "", "", "image/png", "\x89PNG"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := blogHandler(dir)
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+			resp := w.Result()
+			if want, got := http.StatusOK, resp.StatusCode; want != got {
+				t.Errorf("wrote wrong HTTP status, want= %v, got= %v", want, got)
+			}
+			if want, got := tc.expectedEncoding, resp.Header.Get("Content-Encoding"); want != got {
+				t.Errorf("wrote wrong Content-Encoding, want= %q, got= %q", want, got)
+			}
+			if want, got := tc.expectedVary, resp.Header.Get("Vary"); want != got {
+				t.Errorf("wrote wrong Vary, want= %q, got= %q", want, got)
+			}
+			if want, got := tc.expectedType, resp.Header.Get("Content-Type"); want != got {
+				t.Errorf("wrote wrong Content-Type, want= %q, got= %q", want, got)
+			}
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			if tc.expectedEncoding == "gzip" && len(body) > 0 {
+				body = []byte(gunzipBytes(t, body))
+			}
+			if want, got := tc.expectedBody, string(body); want != got {
+				t.Errorf("wrote wrong body,\n  want= %q\n   got= %q", want, got)
+			}
+		})
+	}
+}
+
+func TestBlogHandlerCompressionETag(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+	handler := blogHandler("testdata")
+
+	etags := make(map[string]bool)
+	for _, acceptEncoding := range []string{"", "gzip"} {
+		req := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+
+		handler(w, req)
+		etag := w.Result().Header.Get("ETag")
+
+		req = httptest.NewRequest(http.MethodGet, "/style.css", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		req.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+
+		handler(w, req)
+		if want, got := http.StatusNotModified, w.Result().StatusCode; want != got {
+			t.Errorf("wrote wrong HTTP status for %q, want= %v, got= %v", acceptEncoding, want, got)
+		}
+		etags[etag] = true
+	}
+
+	if len(etags) != 2 {
+		t.Errorf("want each encoding to have its own ETag, got= %v", etags)
+	}
+}
@@ -0,0 +1,193 @@
+package blog
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exklamationmark/glog"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/exklamationmark/notebook/internal/metrics"
+	"github.com/exklamationmark/notebook/internal/middlewares/redirect"
+)
+
+// maxNotFoundPaths bounds the paths counted by notebook_http_not_found_total,
+// as anyone can request new ones; others are counted as "other".
+const maxNotFoundPaths = 1000
+
+// Metrics records requests, redirects and the expiry of ACME certificates in reg,
+// e.g to be served on /metrics.
+func Metrics(reg *metrics.Registry) func(*config) {
+	return func(c *config) {
+		c.metrics = reg
+	}
+}
+
+type blogMetrics struct {
+	requests *metrics.CounterVec
+	latency  *metrics.HistogramVec
+	bytes    *metrics.CounterVec
+	notFound *metrics.CounterVec
+
+	mu            sync.Mutex
+	notFoundPaths map[string]struct{}
+}
+
+func newBlogMetrics(reg *metrics.Registry, rd *redirect.Handler, cache autocert.Cache, domains []string) *blogMetrics {
+	m := &blogMetrics{
+		requests: reg.NewCounterVec("notebook_http_requests_total",
+			"Requests served, by status code and class of path.", "code", "class"),
+		latency: reg.NewHistogramVec("notebook_http_request_duration_seconds",
+			"Time to serve requests, by status code and class of path.", metrics.DefaultBuckets, "code", "class"),
+		bytes: reg.NewCounterVec("notebook_http_response_bytes_total",
+			"Bytes of response bodies, by class of path.", "class"),
+		notFound: reg.NewCounterVec("notebook_http_not_found_total",
+			"Requests for missing files, by path.", "path"),
+		notFoundPaths: map[string]struct{}{},
+	}
+
+	reg.NewCounterFunc("notebook_redirects_total",
+		"Requests redirected, by redirection.", func() []metrics.Sample {
+			hits := rd.Hits()
+			samples := make([]metrics.Sample, 0, len(hits))
+			for _, h := range hits {
+				samples = append(samples, metrics.Sample{LabelValues: []string{h.From, h.To}, Value: float64(h.Count)})
+			}
+			return samples
+		}, "from", "to")
+
+	reg.NewGaugeFunc("notebook_acme_certificate_expiry_timestamp_seconds",
+		"Expiry of the certificates in the ACME cache, in seconds since epoch.", func() []metrics.Sample {
+			return certExpiries(cache, domains)
+		}, "domain", "key")
+
+	return m
+}
+
+func (m *blogMetrics) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		code, class := strconv.Itoa(sw.status), pathClass(r.URL.Path)
+		m.requests.Inc(code, class)
+		m.latency.Observe(time.Since(start).Seconds(), code, class)
+		m.bytes.Add(float64(sw.bytes), class)
+		if sw.status == http.StatusNotFound {
+			m.notFound.Inc(m.notFoundPath(r.URL.Path))
+		}
+	})
+}
+
+func (m *blogMetrics) notFoundPath(p string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, seen := m.notFoundPaths[p]; !seen {
+		if len(m.notFoundPaths) >= maxNotFoundPaths {
+			return "other"
+		}
+		m.notFoundPaths[p] = struct{}{}
+	}
+
+	return p
+}
+
+// pathClass groups paths to keep the number of series small: page, feed, asset or other.
+func pathClass(p string) string {
+	switch ext := path.Ext(p); ext {
+	case "", ".html":
+		return "page"
+	case ".xml", ".atom", ".rss":
+		return "feed"
+	case ".css", ".js", ".ico", ".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".woff", ".woff2":
+		return "asset"
+	}
+
+	return "other"
+}
+
+// certExpiries reads the certificates autocert cached for domains,
+// under <domain> for ECDSA keys and <domain>+rsa for RSA ones.
+func certExpiries(cache autocert.Cache, domains []string) []metrics.Sample {
+	if cache == nil {
+		return nil
+	}
+
+	var samples []metrics.Sample
+	for _, d := range domains {
+		for key, name := range map[string]string{"ecdsa": d, "rsa": d + "+rsa"} {
+			b, err := cache.Get(context.Background(), name)
+			if err == autocert.ErrCacheMiss {
+				continue
+			}
+			if err != nil {
+				glog.Errorf("cannot read %q from ACME cache, err= %v", name, err)
+				continue
+			}
+
+			cert, err := parseCachedCert(b)
+			if err != nil {
+				glog.Errorf("cannot parse certificate %q in ACME cache, err= %v", name, err)
+				continue
+			}
+			samples = append(samples, metrics.Sample{
+				LabelValues: []string{d, key},
+				Value:       float64(cert.NotAfter.Unix()),
+			})
+		}
+	}
+
+	return samples
+}
+
+// parseCachedCert returns the leaf certificate of an autocert cache entry:
+// a PEM private key followed by the PEM certificate chain.
+func parseCachedCert(b []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return nil, errors.Errorf("no certificate found")
+		}
+		if strings.HasSuffix(block.Type, "CERTIFICATE") {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// statusWriter records the status and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+
+	return n, err
+}
@@ -0,0 +1,163 @@
+package blog
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/exklamationmark/notebook/internal/metrics"
+	"github.com/exklamationmark/notebook/internal/middlewares/redirect"
+)
+
+// cachedCert returns an autocert cache entry for domain expiring at notAfter.
+func cachedCert(t *testing.T, domain string, notAfter time.Time) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key, err= %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate, err= %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("cannot marshal key, err= %v", err)
+	}
+
+	var buf bytes.Buffer
+	pem.Encode(&buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	return buf.Bytes()
+}
+
+func TestBlogHandlerMetrics(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+
+	notAfter := time.Date(2018, 10, 19, 0, 0, 0, 0, time.UTC)
+	dir, err := ioutil.TempDir(os.TempDir(), "blog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+	cache := autocert.DirCache(dir)
+	if err := cache.Put(context.Background(), "example.com", cachedCert(t, "example.com", notAfter)); err != nil {
+		t.Fatalf("cannot write to ACME cache, err= %v", err)
+	}
+
+	from, _ := url.Parse("https://subdomain.example.com/")
+	to, _ := url.Parse("https://example.com/")
+	reg := metrics.NewRegistry()
+	srv, err := New("testdata", "admin@example.com",
+		append(exampleDomains, "subdomain.example.com"),
+		Redirect(redirect.Redirections{
+			redirect.Redirection{FromURL: *from, ToURL: *to},
+		}),
+		ACMECache(cache),
+		Metrics(reg),
+	)
+	if err != nil {
+		t.Fatalf("want New() to return no error, got= %v", err)
+	}
+
+	for _, u := range []string{
+		"https://example.com/",
+		"https://example.com/sample",
+		"https://example.com/style.css",
+		"https://example.com/feed.atom",
+		"https://example.com/non-existing",
+		"https://example.com/non-existing",
+		"https://subdomain.example.com/",
+	} {
+		srv.BlogHandler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, u, nil))
+	}
+
+	var buf bytes.Buffer
+	if _, err := reg.WriteTo(&buf); err != nil {
+		t.Fatalf("want WriteTo() to return no error, got= %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		`notebook_http_requests_total{code="200",class="page"} 2`,
+		`notebook_http_requests_total{code="200",class="asset"} 1`,
+		`notebook_http_requests_total{code="200",class="feed"} 1`,
+		`notebook_http_requests_total{code="404",class="page"} 2`,
+		`notebook_http_requests_total{code="301",class="page"} 1`,
+		`notebook_http_request_duration_seconds_count{code="200",class="page"} 2`,
+		`notebook_http_not_found_total{path="/non-existing"} 2`,
+		`notebook_redirects_total{from="https://subdomain.example.com/",to="https://example.com/"} 1`,
+		fmt.Sprintf(`notebook_acme_certificate_expiry_timestamp_seconds{domain="example.com",key="ecdsa"} %d`, notAfter.Unix()),
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("want metrics to contain %q, got=\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `notebook_http_response_bytes_total{class="asset"} 0`) {
+		t.Errorf("want bytes served to be counted, got=\n%s", got)
+	}
+}
+
+func TestPathClass(t *testing.T) {
+	var testCases = []struct {
+		path     string
+		expected string
+	}{
+		{"/", "page"},
+		{"/2018/07/21/post", "page"},
+		{"/about.html", "page"},
+		{"/feed.atom", "feed"},
+		{"/sitemap.xml", "feed"},
+		{"/builtin.0123456789.css", "asset"},
+		{"/favicon.ico", "asset"},
+		{"/robots.txt", "other"},
+	}
+
+	for _, tc := range testCases {
+		if want, got := tc.expected, pathClass(tc.path); want != got {
+			t.Errorf("wrong class for %q, want= %q, got= %q", tc.path, want, got)
+		}
+	}
+}
+
+func TestNotFoundPathLimit(t *testing.T) {
+	m := newBlogMetrics(metrics.NewRegistry(), &redirect.Handler{}, nil, nil)
+	for i := 0; i < maxNotFoundPaths; i++ {
+		m.notFoundPath(fmt.Sprintf("/%d", i))
+	}
+
+	if want, got := "/0", m.notFoundPath("/0"); want != got {
+		t.Errorf("wrong label for a known path, want= %q, got= %q", want, got)
+	}
+	if want, got := "other", m.notFoundPath("/new"); want != got {
+		t.Errorf("wrong label past the limit, want= %q, got= %q", want, got)
+	}
+}
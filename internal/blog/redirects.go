@@ -0,0 +1,168 @@
+package blog
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exklamationmark/glog"
+	"github.com/pkg/errors"
+
+	"github.com/exklamationmark/notebook/internal/middlewares/redirect"
+)
+
+// RedirectsFile adds the redirections in fname (see redirect.ParseFile) after
+// the ones given with Redirect. WatchRedirects reloads it.
+func RedirectsFile(fname string) func(*config) {
+	return func(c *config) {
+		c.redirectsFile = fname
+	}
+}
+
+// AliasesFile adds redirections from the aliases of posts to the posts, in fname
+// as generated by staticgen (see staticgen.AliasesFile), before the ones given with
+// Redirect, which win over them. A missing file is ignored. WatchRedirects reloads it.
+func AliasesFile(fname string) func(*config) {
+	return func(c *config) {
+		c.aliasesFile = fname
+	}
+}
+
+// allRedirections returns the redirections of the aliases file, then the ones given
+// with Redirect, then the ones in the redirects file.
+func (c *config) allRedirections() (redirect.Redirections, error) {
+	var rds redirect.Redirections
+	if _, err := os.Stat(c.aliasesFile); len(c.aliasesFile) > 0 && !os.IsNotExist(err) {
+		aliases, err := redirect.ParseFile(c.aliasesFile)
+		if err != nil {
+			return nil, err
+		}
+		rds = append(rds, aliases...)
+	}
+	rds = append(rds, c.redirections...)
+	if len(c.redirectsFile) < 1 {
+		return rds, nil
+	}
+
+	fromFile, err := redirect.ParseFile(c.redirectsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(rds, fromFile...), nil
+}
+
+// ReloadRedirects reads the redirects file again; on error, the previous redirections are kept.
+func (srv *Server) ReloadRedirects() error {
+	rds, err := srv.allRedirections()
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(srv.redirects.Reload(rds), "invalid redirects in %s", srv.redirectFiles())
+}
+
+// CheckRedirects finds loops, chains, duplicates and redirections to missing pages
+// in the redirections given with AliasesFile, Redirect and RedirectsFile, see redirect.Lint.
+func (srv *Server) CheckRedirects() ([]redirect.Problem, error) {
+	rds, err := srv.allRedirections()
+	if err != nil {
+		return nil, err
+	}
+
+	exists := func(path string) bool {
+		_, err := os.Stat(fileToServe(srv.htmlDir, path))
+		return err == nil
+	}
+	return redirect.Lint(rds, srv.domains, exists)
+}
+
+// WarnRedirectProblems logs the problems found by CheckRedirects, as they don't stop the server.
+func (srv *Server) WarnRedirectProblems() {
+	problems, err := srv.CheckRedirects()
+	if err != nil {
+		glog.Errorf("cannot check redirects, err= %v", err)
+		return
+	}
+	for _, p := range problems {
+		glog.Warningf("%v", p)
+	}
+}
+
+// WatchRedirects reloads the redirects and aliases files when they change, checking
+// every interval, and when reload receives, e.g SIGHUP. It returns when ctx is done.
+func (srv *Server) WatchRedirects(ctx context.Context, interval time.Duration, reload <-chan os.Signal) {
+	if len(srv.redirectsFile) < 1 && len(srv.aliasesFile) < 1 {
+		return
+	}
+
+	prev := srv.loaded
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-reload:
+			glog.V(0).Infof("received %v, reloading %s", sig, srv.redirectFiles())
+		case <-ticker.C:
+			cur := srv.statRedirectFiles()
+			if cur == prev {
+				continue
+			}
+			prev = cur
+			glog.V(0).Infof("%s changed, reloading", srv.redirectFiles())
+		}
+
+		if err := srv.ReloadRedirects(); err != nil {
+			glog.Errorf("cannot reload redirects, keeping the previous ones, err= %v", err)
+			continue
+		}
+		glog.V(0).Infof("reloaded redirects from %s", srv.redirectFiles())
+		srv.WarnRedirectProblems()
+	}
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// redirectFilesState is the state of the files that redirections are read from.
+type redirectFilesState struct {
+	redirects fileState
+	aliases   fileState
+}
+
+func (c *config) statRedirectFiles() redirectFilesState {
+	return redirectFilesState{
+		redirects: statFile(c.redirectsFile),
+		aliases:   statFile(c.aliasesFile),
+	}
+}
+
+// redirectFiles names the files that redirections are read from, for logs and errors.
+func (c *config) redirectFiles() string {
+	var fnames []string
+	for _, fname := range []string{c.aliasesFile, c.redirectsFile} {
+		if len(fname) > 0 {
+			fnames = append(fnames, strconv.Quote(fname))
+		}
+	}
+
+	return strings.Join(fnames, " and ")
+}
+
+func statFile(fname string) fileState {
+	if len(fname) < 1 {
+		return fileState{}
+	}
+	info, err := os.Stat(fname)
+	if err != nil {
+		return fileState{}
+	}
+
+	return fileState{modTime: info.ModTime(), size: info.Size()}
+}
@@ -0,0 +1,184 @@
+package blog
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/exklamationmark/notebook/internal/middlewares/redirect"
+)
+
+func writeRedirects(t *testing.T, fname, content string, modTime time.Time) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatalf("cannot write %q, err= %v", fname, err)
+	}
+	if err := os.Chtimes(fname, modTime, modTime); err != nil {
+		t.Fatalf("cannot change times of %q, err= %v", fname, err)
+	}
+}
+
+func location(srv *Server, u string) string {
+	w := httptest.NewRecorder()
+	srv.BlogHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, u, nil))
+
+	return w.Result().Header.Get("Location")
+}
+
+// waitForLocation polls until u redirects to want, as reloads are asynchronous.
+func waitForLocation(t *testing.T, srv *Server, u, want string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for location(srv, u) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("wrong Location for %q, want= %q, got= %q", u, want, location(srv, u))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatchRedirects(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "blog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "_redirects")
+	start := time.Now().Add(-time.Hour)
+	writeRedirects(t, fname, "/old /new\n", start)
+
+	srv, err := New("testdata", "admin@example.com", exampleDomains, RedirectsFile(fname))
+	if err != nil {
+		t.Fatalf("want New() to return no error, got= %v", err)
+	}
+	if want, got := "/new", location(srv, "https://example.com/old"); want != got {
+		t.Errorf("wrong Location, want= %q, got= %q", want, got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reload := make(chan os.Signal, 1)
+	go srv.WatchRedirects(ctx, 10*time.Millisecond, reload)
+
+	// file change
+	writeRedirects(t, fname, "/old /newer 302\n", start.Add(time.Minute))
+	waitForLocation(t, srv, "https://example.com/old", "/newer")
+
+	// bad file keeps the previous redirections
+	writeRedirects(t, fname, "https://example.org/old /new\n", start.Add(2*time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if want, got := "/newer", location(srv, "https://example.com/old"); want != got {
+		t.Errorf("wrong Location after a bad reload, want= %q, got= %q", want, got)
+	}
+
+	// signal, without a change of modification time or size
+	writeRedirects(t, fname, "/old /newest\n", start.Add(2*time.Minute))
+	writeRedirects(t, fname, "/old /nevest\n", start.Add(2*time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	reload <- syscall.SIGHUP
+	waitForLocation(t, srv, "https://example.com/old", "/nevest")
+}
+
+func TestAliasesFile(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "blog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "_aliases")
+
+	// missing until the site is generated
+	srv, err := New("testdata", "admin@example.com", exampleDomains, AliasesFile(fname))
+	if err != nil {
+		t.Fatalf("want New() to return no error for a missing aliases file, got= %v", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	writeRedirects(t, fname, "# generated\n/old-sample /sample\n/draft /sample\n", start)
+	from, _ := url.Parse("/draft")
+	to, _ := url.Parse("/resume")
+	srv, err = New("testdata", "admin@example.com", exampleDomains,
+		AliasesFile(fname),
+		Redirect(redirect.Redirections{
+			redirect.Redirection{FromURL: *from, ToURL: *to},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("want New() to return no error, got= %v", err)
+	}
+	for u, want := range map[string]string{
+		"https://example.com/old-sample": "/sample",
+		"https://example.com/draft":      "/resume", // Redirect wins over aliases
+	} {
+		if got := location(srv, u); want != got {
+			t.Errorf("wrong Location for %q, want= %q, got= %q", u, want, got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.WatchRedirects(ctx, 10*time.Millisecond, nil)
+
+	writeRedirects(t, fname, "/older-sample /sample\n", start.Add(time.Minute))
+	waitForLocation(t, srv, "https://example.com/older-sample", "/sample")
+	if want, got := "", location(srv, "https://example.com/old-sample"); want != got {
+		t.Errorf("wrong Location for a removed alias, want= %q, got= %q", want, got)
+	}
+}
+
+func TestNewInvalidRedirectsFile(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "blog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "redirects.yaml")
+	writeRedirects(t, fname, "- from: https://example.org/\n  to: /\n", time.Now())
+
+	if _, err := New("testdata", "admin@example.com", exampleDomains, RedirectsFile(fname)); err == nil {
+		t.Errorf("want New() to return an error, got none")
+	}
+}
+
+func TestCheckRedirects(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "blog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "_redirects")
+	writeRedirects(t, fname, "/old-sample /sample\n/resume /cv\n", time.Now())
+
+	from, _ := url.Parse("https://www.example.com/sample")
+	to, _ := url.Parse("https://example.com/old-sample")
+	srv, err := New("testdata", "admin@example.com", exampleDomains,
+		Redirect(redirect.Redirections{{FromURL: *from, ToURL: *to}}),
+		RedirectsFile(fname),
+	)
+	if err != nil {
+		t.Fatalf("want New() to return no error, got= %v", err)
+	}
+
+	problems, err := srv.CheckRedirects()
+	if err != nil {
+		t.Fatalf("want CheckRedirects() to return no error, got= %v", err)
+	}
+	expected := []redirect.Problem{
+		{Kind: redirect.Chain, Index: 0, Message: "www.example.com/sample → example.com/old-sample → example.com/sample, redirect to example.com/sample directly"},
+		{Kind: redirect.MissingTarget, Index: 2, Message: "/resume redirects to /cv, which doesn't exist"},
+	}
+	if want, got := expected, problems; !cmp.Equal(want, got) {
+		t.Errorf("wrong problems\n  diff= %v", cmp.Diff(want, got))
+	}
+}
@@ -2,6 +2,9 @@ package blog
 
 import (
 	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -11,12 +14,24 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/acme/autocert"
 
+	"github.com/exklamationmark/notebook/internal/metrics"
+	"github.com/exklamationmark/notebook/internal/middlewares/accesslog"
+	"github.com/exklamationmark/notebook/internal/middlewares/canonical"
 	"github.com/exklamationmark/notebook/internal/middlewares/redirect"
+	"github.com/exklamationmark/notebook/internal/middlewares/security"
 )
 
 type config struct {
-	htmlDir      string
-	redirections redirect.Redirections
+	htmlDir       string
+	redirections  redirect.Redirections
+	redirectsFile string
+	aliasesFile   string
+	acmeCache     autocert.Cache
+	cacheRules    CacheRules
+	security      *securityConfig
+	accessLog     *accessLogConfig
+	metrics       *metrics.Registry
+	canonical     *canonicalConfig
 }
 
 type opt func(*config)
@@ -24,6 +39,9 @@ type opt func(*config)
 type Server struct {
 	config
 	acmeManager *autocert.Manager
+	domains     []string
+	redirects   *redirect.Handler
+	loaded      redirectFilesState // when New read them
 	handler     http.Handler
 }
 
@@ -33,27 +51,60 @@ func New(htmlDir, adminEmail string, domains []string, opts ...opt) (*Server, er
 		return nil, errors.Wrapf(err, "cannot find absolute path to %q", htmlDir)
 	}
 
-	c := config{htmlDir: absHTMLDir}
+	c := config{htmlDir: absHTMLDir, cacheRules: CacheRules{}}
+	for ext, value := range DefaultCacheRules {
+		c.cacheRules[ext] = value
+	}
 	for _, opt := range opts {
 		opt(&c)
 	}
 
 	manager := autocert.Manager{
 		Prompt:     autocert.AcceptTOS,
-		Cache:      autocert.DirCache(c.htmlDir),
+		Cache:      c.acmeCache,
 		HostPolicy: autocert.HostWhitelist(domains...),
 		Email:      adminEmail,
 	}
 
-	next := http.HandlerFunc(blogHandler(c.htmlDir))
-	handler, err := redirect.NewHandler(next, c.redirections, domains...)
+	loaded := c.statRedirectFiles() // before reading, to reload if they change in between
+	rds, err := c.allRedirections()
+	if err != nil {
+		return nil, err
+	}
+	next := cacheControl(http.HandlerFunc(blogHandler(c.htmlDir)), c.cacheRules)
+	rd, err := redirect.NewHandler(next, rds, domains...)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot create handler")
 	}
+	var handler http.Handler = rd
+	if c.canonical != nil {
+		handler, err = canonical.NewHandler(handler, c.canonical.host, rd)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create canonical URL handler")
+		}
+	}
+	if c.metrics != nil {
+		handler = newBlogMetrics(c.metrics, rd, c.acmeCache, domains).handler(handler)
+	}
+	if c.security != nil {
+		handler, err = security.NewHandler(handler, c.security.policy, c.security.overrides...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create security headers handler")
+		}
+	}
+	if c.accessLog != nil {
+		handler, err = accesslog.NewHandler(handler, c.accessLog.out, c.accessLog.format, c.accessLog.trustedProxies...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create access log handler")
+		}
+	}
 
 	return &Server{
 		config:      c,
 		acmeManager: &manager,
+		domains:     domains,
+		redirects:   rd,
+		loaded:      loaded,
 		handler:     handler,
 	}, nil
 }
@@ -64,6 +115,50 @@ func Redirect(rds redirect.Redirections) func(*config) {
 	}
 }
 
+type securityConfig struct {
+	policy    security.Policy
+	overrides []security.Override
+}
+
+// Security sets security headers (Content-Security-Policy, HSTS, ...) on every response,
+// following p or the first of overrides matching the requested path.
+func Security(p security.Policy, overrides ...security.Override) func(*config) {
+	return func(c *config) {
+		c.security = &securityConfig{policy: p, overrides: overrides}
+	}
+}
+
+type canonicalConfig struct {
+	host string
+}
+
+// Canonical permanently redirects requests to the canonical URL of pages:
+// on host (unless empty), and without .html or trailing slashes.
+func Canonical(host string) func(*config) {
+	return func(c *config) {
+		c.canonical = &canonicalConfig{host: host}
+	}
+}
+
+type accessLogConfig struct {
+	out            io.Writer
+	format         accesslog.Format
+	trustedProxies []string
+}
+
+// AccessLog writes a line per request to out, e.g a *accesslog.RotatingFile.
+// Requests from trustedProxies are logged with the client address in X-Forwarded-For.
+// A nil out disables the access log.
+func AccessLog(out io.Writer, format accesslog.Format, trustedProxies ...string) func(*config) {
+	return func(c *config) {
+		if out == nil {
+			c.accessLog = nil
+			return
+		}
+		c.accessLog = &accessLogConfig{out: out, format: format, trustedProxies: trustedProxies}
+	}
+}
+
 func (srv *Server) HTTPRedirectHandler() http.Handler {
 	return srv.acmeManager.HTTPHandler(nil)
 }
@@ -83,6 +178,7 @@ var (
 		http.StatusInternalServerError: []byte("<html><h1>Internal server error</h1><p>Sorry, something went wrong</p></html>"),
 		http.StatusNotFound:            []byte("<html><h1>Not found</h1><p>Sorry, but our princess is in another castle</p></html>"),
 		http.StatusBadRequest:          []byte("<html><h1>Bad request</h1><p>Sorry, this we can't serve this</p></html>"),
+		http.StatusMethodNotAllowed:    []byte("<html><h1>Method not allowed</h1><p>Sorry, this is a read-only blog</p></html>"),
 	}
 )
 
@@ -114,9 +210,32 @@ var (
 		".woff",
 		".woff2",
 		".ttf",
+		".txt",
+		".xml",
+		".atom",
+		".rss",
+	}
+
+	// contentTypes overrides the MIME type http.ServeFile would guess from
+	// the system's mime database, which often doesn't know about feeds.
+	contentTypes = map[string]string{
+		".xml":  "application/xml; charset=utf-8",
+		".atom": "application/atom+xml; charset=utf-8",
+		".rss":  "application/rss+xml; charset=utf-8",
+		".txt":  "text/plain; charset=utf-8",
 	}
 )
 
+// contentType returns the MIME type of fname, or "" to let http.ServeFile sniff it.
+func contentType(fname string) string {
+	ext := filepath.Ext(fname)
+	if contentType, exist := contentTypes[ext]; exist {
+		return contentType
+	}
+
+	return mime.TypeByExtension(ext)
+}
+
 func isServable(ext string) bool {
 	for _, servable := range servableExts {
 		if servable == ext {
@@ -139,15 +258,32 @@ func fileToServe(htmlDir, path string) string {
 	}
 }
 
+// allowedMethods is the Allow header of responses to OPTIONS and unsupported methods.
+const allowedMethods = "GET, HEAD, OPTIONS"
+
+// etag identifies a version of a file by its size and modification time,
+// which change whenever it is regenerated.
+func etag(stat os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size())
+}
+
 func blogHandler(htmlDir string) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			serveErrPage(w, r, http.StatusBadRequest)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodOptions:
+			w.Header().Set("Allow", allowedMethods)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		default:
+			w.Header().Set("Allow", allowedMethods)
+			serveErrPage(w, r, http.StatusMethodNotAllowed)
 			return
 		}
 
 		fname := fileToServe(htmlDir, r.URL.Path)
-		if _, err := os.Stat(fname); err != nil {
+		stat, err := os.Stat(fname)
+		if err != nil {
 			if os.IsNotExist(err) {
 				glog.Errorf("%q requested but not found", fname)
 				serveErrPage(w, r, http.StatusNotFound)
@@ -159,8 +295,20 @@ func blogHandler(htmlDir string) func(http.ResponseWriter, *http.Request) {
 			return
 		}
 
+		// set explicitly, as it can't be guessed from the name of compressed siblings
+		if contentType := contentType(fname); len(contentType) > 0 {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if serveCompressed(w, r, fname, stat) {
+			glog.V(1).Infof("served %q compressed", fname)
+			return
+		}
+
+		// http.ServeFile answers If-None-Match against it, and If-Modified-Since
+		// and HEAD on its own
+		w.Header().Set("ETag", etag(stat))
 		serveFile(w, r, fname)
-		glog.V(0).Infof("served %q", fname)
+		glog.V(1).Infof("served %q", fname)
 	}
 }
 
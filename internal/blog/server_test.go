@@ -1,14 +1,18 @@
 package blog
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/exklamationmark/notebook/internal/middlewares/accesslog"
 	"github.com/exklamationmark/notebook/internal/middlewares/redirect"
+	"github.com/exklamationmark/notebook/internal/middlewares/security"
 )
 
 func TestBlogHandlerServeFile(t *testing.T) {
@@ -98,8 +102,22 @@ func TestBlogHandlerServeError(t *testing.T) {
 			name:           "POST",
 			method:         http.MethodPost,
 			path:           "/sample",
-			expectedStatus: http.StatusBadRequest,
-			expectedBody:   string(defaultResponses[http.StatusBadRequest]),
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   string(defaultResponses[http.StatusMethodNotAllowed]),
+		},
+		{
+			name:           "DELETE",
+			method:         http.MethodDelete,
+			path:           "/sample",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   string(defaultResponses[http.StatusMethodNotAllowed]),
+		},
+		{
+			name:           "OPTIONS",
+			method:         http.MethodOptions,
+			path:           "/sample",
+			expectedStatus: http.StatusNoContent,
+			expectedBody:   "",
 		},
 		{
 			name:           "non-existing file",
@@ -127,6 +145,11 @@ func TestBlogHandlerServeError(t *testing.T) {
 			if want, got := tc.expectedBody, string(body); want != got {
 				t.Errorf("wrote wrong body,\n  want= %q\n   got= %q", want, got)
 			}
+			if tc.method != http.MethodGet {
+				if want, got := "GET, HEAD, OPTIONS", resp.Header.Get("Allow"); want != got {
+					t.Errorf("wrote wrong Allow header, want= %q, got= %q", want, got)
+				}
+			}
 		})
 	}
 }
@@ -252,3 +275,243 @@ func TestBlogHandlerForward(t *testing.T) {
 		t.Errorf("forwarded to wrong URL\n  want= %q\n   got= %q", want, got)
 	}
 }
+
+func TestBlogHandlerContentType(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+
+	var testCases = []struct {
+		path                string
+		expectedContentType string
+	}{
+		{"/feed.atom", "application/atom+xml; charset=utf-8"},
+		{"/feed.rss", "application/rss+xml; charset=utf-8"},
+		{"/sample", "text/html; charset=utf-8"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			handler := blogHandler("testdata")
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+			resp := w.Result()
+			if want, got := http.StatusOK, resp.StatusCode; want != got {
+				t.Errorf("wrote wrong HTTP status, want= %v, got= %v", want, got)
+			}
+			if want, got := tc.expectedContentType, resp.Header.Get("Content-Type"); want != got {
+				t.Errorf("wrote wrong Content-Type, want= %v, got= %v", want, got)
+			}
+		})
+	}
+}
+
+func TestBlogHandlerHead(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+	handler := blogHandler("testdata")
+
+	get := httptest.NewRecorder()
+	handler(get, httptest.NewRequest(http.MethodGet, "/sample", nil))
+	head := httptest.NewRecorder()
+	handler(head, httptest.NewRequest(http.MethodHead, "/sample", nil))
+
+	resp := head.Result()
+	if want, got := http.StatusOK, resp.StatusCode; want != got {
+		t.Errorf("wrote wrong HTTP status, want= %v, got= %v", want, got)
+	}
+	for _, header := range []string{"Content-Type", "Content-Length", "ETag", "Last-Modified"} {
+		if want, got := get.Result().Header.Get(header), resp.Header.Get(header); len(want) == 0 || want != got {
+			t.Errorf("wrote wrong %s header, want= %q (as GET), got= %q", header, want, got)
+		}
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if len(body) > 0 {
+		t.Errorf("want no body for HEAD, got= %q", body)
+	}
+}
+
+func TestBlogHandlerConditional(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+	handler := blogHandler("testdata")
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/sample", nil))
+	etag, lastModified := w.Result().Header.Get("ETag"), w.Result().Header.Get("Last-Modified")
+	if len(etag) == 0 || len(lastModified) == 0 {
+		t.Fatalf("want ETag and Last-Modified headers, got= %q, %q", etag, lastModified)
+	}
+
+	var testCases = []struct {
+		name           string
+		method         string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{"matching ETag", http.MethodGet, "If-None-Match", etag, http.StatusNotModified},
+		{"matching ETag with HEAD", http.MethodHead, "If-None-Match", etag, http.StatusNotModified},
+		{"one of many ETags", http.MethodGet, "If-None-Match", `"other", ` + etag, http.StatusNotModified},
+		{"stale ETag", http.MethodGet, "If-None-Match", `"stale"`, http.StatusOK},
+		{"not modified since", http.MethodGet, "If-Modified-Since", lastModified, http.StatusNotModified},
+		{"modified since", http.MethodGet, "If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/sample", nil)
+			req.Header.Set(tc.header, tc.value)
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+			resp := w.Result()
+			if want, got := tc.expectedStatus, resp.StatusCode; want != got {
+				t.Errorf("wrote wrong HTTP status, want= %v, got= %v", want, got)
+			}
+			if want, got := etag, resp.Header.Get("ETag"); want != got {
+				t.Errorf("wrote wrong ETag, want= %q, got= %q", want, got)
+			}
+		})
+	}
+}
+
+func TestBlogHandlerSecurity(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+
+	from, _ := url.Parse("https://subdomain.example.com/")
+	to, _ := url.Parse("https://example.com/")
+	srv, err := New("testdata", "admin@example.com",
+		append(exampleDomains, "subdomain.example.com"),
+		Redirect(redirect.Redirections{
+			redirect.Redirection{FromURL: *from, ToURL: *to},
+		}),
+		Security(security.Policy{ContentSecurityPolicy: "default-src 'self'"},
+			security.Override{Path: "/sample", Policy: security.Policy{ContentSecurityPolicy: "default-src *"}},
+		),
+	)
+	if err != nil {
+		t.Fatalf("want New() to return no error, got= %v", err)
+	}
+
+	var testCases = []struct {
+		url            string
+		expectedStatus int
+		expectedCSP    string
+	}{
+		{"https://example.com/", http.StatusOK, "default-src 'self'"},
+		{"https://example.com/sample", http.StatusOK, "default-src *"},
+		{"https://example.com/non-existing", http.StatusNotFound, "default-src 'self'"},
+		{"https://subdomain.example.com/", http.StatusMovedPermanently, "default-src 'self'"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			w := httptest.NewRecorder()
+
+			srv.BlogHandler().ServeHTTP(w, req)
+			resp := w.Result()
+			if want, got := tc.expectedStatus, resp.StatusCode; want != got {
+				t.Errorf("wrote wrong HTTP status, want= %v, got= %v", want, got)
+			}
+			if want, got := tc.expectedCSP, resp.Header.Get("Content-Security-Policy"); want != got {
+				t.Errorf("wrote wrong Content-Security-Policy, want= %q, got= %q", want, got)
+			}
+			if want, got := "nosniff", resp.Header.Get("X-Content-Type-Options"); want != got {
+				t.Errorf("wrote wrong X-Content-Type-Options, want= %q, got= %q", want, got)
+			}
+		})
+	}
+}
+
+func TestBlogHandlerAccessLog(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+
+	from, _ := url.Parse("https://subdomain.example.com/")
+	to, _ := url.Parse("https://example.com/")
+	var out bytes.Buffer
+	srv, err := New("testdata", "admin@example.com",
+		append(exampleDomains, "subdomain.example.com"),
+		Redirect(redirect.Redirections{
+			redirect.Redirection{FromURL: *from, ToURL: *to},
+		}),
+		AccessLog(&out, accesslog.Common),
+	)
+	if err != nil {
+		t.Fatalf("want New() to return no error, got= %v", err)
+	}
+
+	for _, u := range []string{"https://example.com/sample", "https://example.com/non-existing", "https://subdomain.example.com/"} {
+		srv.BlogHandler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, u, nil))
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if want, got := 3, len(lines); want != got {
+		t.Fatalf("wrong number of log lines, want= %d, got= %d (%q)", want, got, out.String())
+	}
+	for i, want := range []string{`"GET /sample HTTP/1.1" 200 `, `"GET /non-existing HTTP/1.1" 404 `, `"GET / HTTP/1.1" 301 `} {
+		if got := lines[i]; !strings.Contains(got, want) {
+			t.Errorf("wrong log line, want it to contain %q, got= %q", want, got)
+		}
+	}
+}
+
+func TestBlogHandlerCanonical(t *testing.T) {
+	serveFile = func(w http.ResponseWriter, r *http.Request, fname string) {
+		http.ServeFile(w, r, fname)
+	}
+
+	from, _ := url.Parse("https://example.com/old-sample")
+	to, _ := url.Parse("https://example.com/sample")
+	srv, err := New("testdata", "admin@example.com", exampleDomains,
+		Redirect(redirect.Redirections{
+			redirect.Redirection{FromURL: *from, ToURL: *to},
+		}),
+		Canonical("example.com"),
+	)
+	if err != nil {
+		t.Fatalf("want New() to return no error, got= %v", err)
+	}
+
+	var testCases = []struct {
+		url              string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"https://example.com/sample", http.StatusOK, ""},
+		{"https://example.com/style.css", http.StatusOK, ""},
+		{"https://example.com/sample.html", http.StatusMovedPermanently, "/sample"},
+		{"https://example.com/sample/", http.StatusMovedPermanently, "/sample"},
+		{"https://example.com/index.html", http.StatusMovedPermanently, "/"},
+		{"https://www.example.com/sample", http.StatusMovedPermanently, "//example.com/sample"},
+		{"https://www.example.com/old-sample.html", http.StatusMovedPermanently, "https://example.com/sample"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			srv.BlogHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			resp := w.Result()
+			if want, got := tc.expectedStatus, resp.StatusCode; want != got {
+				t.Errorf("wrote wrong HTTP status, want= %v, got= %v", want, got)
+			}
+			if want, got := tc.expectedLocation, resp.Header.Get("Location"); want != got {
+				t.Errorf("wrote wrong Location, want= %q, got= %q", want, got)
+			}
+		})
+	}
+
+	if _, err := New("testdata", "admin@example.com", exampleDomains, Canonical("example.com:443")); err == nil {
+		t.Errorf("want New() to return an error for an invalid canonical host, got none")
+	}
+}
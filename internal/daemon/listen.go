@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Prefixes of the addresses Listen accepts besides host:port.
+const (
+	unixPrefix    = "unix:"
+	systemdPrefix = "systemd:"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd, see sd_listen_fds(3).
+const listenFDsStart = 3
+
+// Listen listens on addr, which is one of:
+//
+//	host:port         TCP, e.g :80 or 127.0.0.1:8080
+//	unix:/path        Unix socket, replacing a stale socket file
+//	systemd:name      socket passed by systemd (FileDescriptorName=name in the .socket unit)
+func Listen(addr string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, unixPrefix):
+		return listenUnix(strings.TrimPrefix(addr, unixPrefix))
+	case strings.HasPrefix(addr, systemdPrefix):
+		return activated(strings.TrimPrefix(addr, systemdPrefix))
+	default:
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot listen on %q", addr)
+		}
+		return ln, nil
+	}
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "cannot remove stale socket %q", path)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot listen on %q", path)
+	}
+	// allow a reverse proxy in the same group to connect
+	if err := os.Chmod(path, 0660); err != nil {
+		ln.Close()
+		return nil, errors.Wrapf(err, "cannot set permissions of socket %q", path)
+	}
+
+	return ln, nil
+}
+
+var (
+	activatedOnce sync.Once
+	activatedLns  map[string]net.Listener
+	activatedErr  error
+)
+
+// activated returns the listener systemd passed under name.
+// Each one can only be used once.
+func activated(name string) (net.Listener, error) {
+	activatedOnce.Do(func() {
+		activatedLns, activatedErr = listenFDs(listenFDsStart)
+	})
+	if activatedErr != nil {
+		return nil, activatedErr
+	}
+
+	ln, exist := activatedLns[name]
+	if !exist {
+		return nil, errors.Errorf("no socket named %q passed by systemd", name)
+	}
+	delete(activatedLns, name)
+
+	return ln, nil
+}
+
+// listenFDs reads the sockets passed by systemd, starting at file descriptor start,
+// from $LISTEN_PID, $LISTEN_FDS and $LISTEN_FDNAMES, then unsets them so child
+// processes don't use them.
+func listenFDs(start int) (map[string]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("no sockets passed by systemd (is the .socket unit enabled?)")
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, errors.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	lns := make(map[string]net.Listener, n)
+	for i := 0; i < n; i++ {
+		name := "unknown" // systemd's default FileDescriptorName
+		if i < len(names) && len(names[i]) > 0 {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(start+i), name)
+		ln, err := net.FileListener(f)
+		f.Close() // FileListener dups the descriptor
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot use socket %q passed by systemd", name)
+		}
+		lns[name] = ln
+	}
+
+	return lns, nil
+}
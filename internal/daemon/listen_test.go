@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenTCP(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("want Listen() to return no error, got= %v", err)
+	}
+	defer ln.Close()
+
+	if want, got := "tcp", ln.Addr().Network(); want != got {
+		t.Errorf("wrong network, want= %q, got= %q", want, got)
+	}
+	if _, err := Listen(ln.Addr().String()); err == nil {
+		t.Errorf("want error when the address is in use")
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "daemon")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "notebook.sock")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil { // e.g left by a crash
+		t.Fatalf("cannot create stale socket, err= %v", err)
+	}
+
+	ln, err := Listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("want Listen() to return no error, got= %v", err)
+	}
+	defer ln.Close()
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("want socket to be created, err= %v", err)
+	}
+	if stat.Mode()&os.ModeSocket == 0 {
+		t.Errorf("want %q to be a socket, got mode= %v", path, stat.Mode())
+	}
+	if want, got := os.FileMode(0660), stat.Mode().Perm(); want != got {
+		t.Errorf("wrong socket permissions, want= %v, got= %v", want, got)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("cannot connect to socket, err= %v", err)
+	}
+	conn.Close()
+}
+
+func TestListenFDs(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen on an ephemeral port, err= %v", err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("cannot get file of listener, err= %v", err)
+	}
+	defer f.Close()
+
+	var testCases = []struct {
+		name      string
+		pid       string
+		fds       string
+		fdNames   string
+		wantName  string
+		wantError bool
+	}{
+		{"named", strconv.Itoa(os.Getpid()), "1", "http", "http", false},
+		{"unnamed", strconv.Itoa(os.Getpid()), "1", "", "unknown", false},
+		{"other process", "1", "1", "http", "", true},
+		{"not activated", "", "", "", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setenv(t, "LISTEN_PID", tc.pid)()
+			defer setenv(t, "LISTEN_FDS", tc.fds)()
+			defer setenv(t, "LISTEN_FDNAMES", tc.fdNames)()
+
+			// listenFDs closes the descriptors it uses, so give it a copy
+			fd, err := syscall.Dup(int(f.Fd()))
+			if err != nil {
+				t.Fatalf("cannot duplicate listener, err= %v", err)
+			}
+
+			lns, err := listenFDs(fd)
+			if tc.wantError {
+				syscall.Close(fd)
+				if err == nil {
+					t.Errorf("want error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want listenFDs() to return no error, got= %v", err)
+			}
+
+			got, exist := lns[tc.wantName]
+			if !exist {
+				t.Fatalf("want socket named %q, got= %v", tc.wantName, lns)
+			}
+			defer got.Close()
+			if want := ln.Addr().String(); got.Addr().String() != want {
+				t.Errorf("wrong socket, want= %s, got= %s", want, got.Addr())
+			}
+			if _, exist := os.LookupEnv("LISTEN_FDS"); exist {
+				t.Errorf("want $LISTEN_FDS to be unset")
+			}
+		})
+	}
+}
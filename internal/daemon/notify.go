@@ -0,0 +1,39 @@
+package daemon
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// States reported to systemd, see sd_notify(3).
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+)
+
+// Notify sends state to the service manager through $NOTIFY_SOCKET,
+// as used by systemd units with Type=notify. It does nothing when the
+// variable is unset, e.g when not started by systemd.
+func Notify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if len(addr) == 0 {
+		return nil
+	}
+	if addr[0] == '@' { // abstract socket
+		addr = "\x00" + addr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return errors.Wrapf(err, "cannot connect to notify socket %q", addr)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return errors.Wrapf(err, "cannot notify %q", state)
+	}
+
+	return nil
+}
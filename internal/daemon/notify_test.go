@@ -0,0 +1,79 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setenv sets the environment variable key to value, until the returned func is called.
+func setenv(t *testing.T, key, value string) func() {
+	prev, set := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("cannot set $%s, err= %v", key, err)
+	}
+
+	return func() {
+		if set {
+			os.Setenv(key, prev)
+			return
+		}
+		os.Unsetenv(key)
+	}
+}
+
+// listenNotify sets $NOTIFY_SOCKET to a socket in a temporary directory,
+// returning the connection to read notifications from and a func to clean up.
+func listenNotify(t *testing.T) (*net.UnixConn, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "daemon")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	addr := filepath.Join(dir, "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("cannot listen on %q, err= %v", addr, err)
+	}
+	unset := setenv(t, "NOTIFY_SOCKET", addr)
+
+	return conn, func() {
+		unset()
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	b := make([]byte, 64)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatalf("cannot read notification, err= %v", err)
+	}
+
+	return string(b[:n])
+}
+
+func TestNotify(t *testing.T) {
+	conn, cleanup := listenNotify(t)
+	defer cleanup()
+
+	for _, state := range []string{Ready, Stopping} {
+		if err := Notify(state); err != nil {
+			t.Fatalf("want Notify(%q) to return no error, got= %v", state, err)
+		}
+		if got := readNotify(t, conn); got != state {
+			t.Errorf("wrong notification, want= %q, got= %q", state, got)
+		}
+	}
+}
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	defer setenv(t, "NOTIFY_SOCKET", "")()
+
+	if err := Notify(Ready); err != nil {
+		t.Errorf("want Notify() to do nothing without $NOTIFY_SOCKET, got= %v", err)
+	}
+}
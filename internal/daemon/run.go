@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/exklamationmark/glog"
+	"github.com/pkg/errors"
+)
+
+// Server is an http.Server with the listener it serves on.
+type Server struct {
+	*http.Server
+	Listener net.Listener
+}
+
+// Run serves srvs (over TLS if they have a TLSConfig) until ctx is done or any of
+// them fails. All of them are then shut down, giving in-flight requests up to
+// drain to complete.
+// systemd is notified once every server is serving, and again when stopping.
+func Run(ctx context.Context, drain time.Duration, srvs ...Server) error {
+	errs := make(chan error, len(srvs))
+	for _, srv := range srvs {
+		go func(srv Server) {
+			var err error
+			if srv.TLSConfig != nil {
+				err = srv.ServeTLS(srv.Listener, "", "")
+			} else {
+				err = srv.Serve(srv.Listener)
+			}
+			if err == http.ErrServerClosed {
+				err = nil
+			}
+			errs <- errors.Wrapf(err, "server on %s failed", srv.Listener.Addr())
+		}(srv)
+		glog.V(0).Infof("listening on %s", srv.Listener.Addr())
+	}
+	if err := Notify(Ready); err != nil {
+		glog.Warningf("cannot notify systemd, err= %v", err)
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		glog.V(0).Infof("shutting down, waiting up to %v for requests to complete", drain)
+	case err = <-errs:
+		glog.Errorf("shutting down after failure, err= %v", err)
+	}
+	if err := Notify(Stopping); err != nil {
+		glog.Warningf("cannot notify systemd, err= %v", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	for _, srv := range srvs {
+		if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+			err = errors.Wrapf(shutdownErr, "cannot shut down server on %s gracefully", srv.Listener.Addr())
+		}
+	}
+
+	return err
+}
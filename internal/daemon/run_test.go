@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func localListener(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen on an ephemeral port, err= %v", err)
+	}
+
+	return ln
+}
+
+func TestRunDrainsRequests(t *testing.T) {
+	conn, cleanup := listenNotify(t)
+	defer cleanup()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := Server{Listener: localListener(t), Server: &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Write([]byte("done"))
+		}),
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, time.Second, srv)
+	}()
+	if got := readNotify(t, conn); got != Ready {
+		t.Fatalf("want %q once listening, got= %q", Ready, got)
+	}
+
+	body := make(chan string)
+	go func() {
+		resp, err := http.Get("http://" + srv.Listener.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+
+	cancel()
+	if got := readNotify(t, conn); got != Stopping {
+		t.Errorf("want %q when shutting down, got= %q", Stopping, got)
+	}
+	close(release)
+
+	if got := <-body; got != "done" {
+		t.Errorf("want in-flight request to complete, got= %q", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("want Run() to return no error, got= %v", err)
+	}
+}
+
+func TestRunDrainTimeout(t *testing.T) {
+	conn, cleanup := listenNotify(t)
+	defer cleanup()
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	srv := Server{Listener: localListener(t), Server: &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}),
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, 50*time.Millisecond, srv)
+	}()
+	readNotify(t, conn)
+
+	go http.Get("http://" + srv.Listener.Addr().String())
+	<-started
+	cancel()
+
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "gracefully") {
+		t.Errorf("want error when requests outlast the drain timeout, got= %v", err)
+	}
+}
+
+func TestRunServeError(t *testing.T) {
+	conn, cleanup := listenNotify(t)
+	defer cleanup()
+
+	failing := localListener(t)
+	failing.Close()
+	srvs := []Server{
+		{Listener: localListener(t), Server: &http.Server{}},
+		{Listener: failing, Server: &http.Server{}},
+	}
+
+	err := Run(context.Background(), time.Second, srvs...)
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("want error from the failing server, got= %v", err)
+	}
+	if got := readNotify(t, conn); got != Ready {
+		t.Errorf("want %q, got= %q", Ready, got)
+	}
+	if got := readNotify(t, conn); got != Stopping {
+		t.Errorf("want %q when the other servers are shut down, got= %q", Stopping, got)
+	}
+}
@@ -0,0 +1,312 @@
+// Package metrics keeps counters and histograms and exposes them
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ContentType of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency buckets, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics, written sorted by name.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+type metric interface {
+	write(buf *bytes.Buffer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+func (r *Registry) register(d desc, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exist := r.metrics[d.name]; exist {
+		panic(fmt.Sprintf("metric %q is already registered", d.name))
+	}
+	r.metrics[d.name] = m
+}
+
+// NewCounterVec registers a counter with the given labels.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name: name, help: help, typ: "counter", labels: labels}, series: map[string]*counter{}}
+	r.register(c.desc, c)
+
+	return c
+}
+
+// NewHistogramVec registers a histogram with the given (increasing) buckets and labels.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{desc: desc{name: name, help: help, typ: "histogram", labels: labels}, buckets: buckets, series: map[string]*histogram{}}
+	r.register(h.desc, h)
+
+	return h
+}
+
+// Sample is a value computed when metrics are collected.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// NewCounterFunc registers a counter whose values are returned by f, e.g kept elsewhere.
+func (r *Registry) NewCounterFunc(name, help string, f func() []Sample, labels ...string) {
+	m := &funcMetric{desc: desc{name: name, help: help, typ: "counter", labels: labels}, f: f}
+	r.register(m.desc, m)
+}
+
+// NewGaugeFunc registers a gauge whose values are returned by f.
+func (r *Registry) NewGaugeFunc(name, help string, f func() []Sample, labels ...string) {
+	m := &funcMetric{desc: desc{name: name, help: help, typ: "gauge", labels: labels}, f: f}
+	r.register(m.desc, m)
+}
+
+// WriteTo writes all metrics in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, 0, len(names))
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		m.write(&buf)
+	}
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), errors.Wrapf(err, "cannot write metrics")
+}
+
+// Handler serves the metrics, e.g on /metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+type desc struct {
+	name, help, typ string
+	labels          []string
+}
+
+func (d desc) writeHeader(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+func (d desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %q has labels %v, got values %v", d.name, d.labels, values))
+	}
+}
+
+// writeSample writes a line name{labels} value; extra is a label added after the others, e.g le.
+func writeSample(buf *bytes.Buffer, name string, labels, values []string, extra string, v float64) {
+	buf.WriteString(name)
+	if len(labels) > 0 || len(extra) > 0 {
+		buf.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, `%s="%s"`, l, escapeLabel(values[i]))
+		}
+		if len(extra) > 0 {
+			if len(labels) > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(extra)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(v))
+	buf.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		return strconv.FormatFloat(v, 'f', -1, 64) // e.g timestamps, without exponent
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	desc
+
+	mu     sync.Mutex
+	series map[string]*counter
+}
+
+type counter struct {
+	values []string
+	value  float64
+}
+
+// Add adds v (not negative) to the counter with the label values.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.checkLabels(labelValues)
+	if v < 0 {
+		panic(fmt.Sprintf("counter %q cannot decrease", c.name))
+	}
+
+	k := seriesKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, exist := c.series[k]
+	if !exist {
+		s = &counter{values: append([]string(nil), labelValues...)}
+		c.series[k] = s
+	}
+	s.value += v
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Len returns the number of label combinations seen.
+func (c *CounterVec) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.series)
+}
+
+func (c *CounterVec) write(buf *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(buf)
+	keys := make([]string, 0, len(c.series))
+	for k := range c.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := c.series[k]
+		writeSample(buf, c.name, c.labels, s.values, "", s.value)
+	}
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Observe adds v to the histogram with the label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+
+	k := seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, exist := h.series[k]
+	if !exist {
+		s = &histogram{values: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) write(buf *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(buf)
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := h.series[k]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(buf, h.name+"_bucket", h.labels, s.values, `le="`+formatFloat(le)+`"`, float64(cumulative))
+		}
+		writeSample(buf, h.name+"_bucket", h.labels, s.values, `le="+Inf"`, float64(s.count))
+		writeSample(buf, h.name+"_sum", h.labels, s.values, "", s.sum)
+		writeSample(buf, h.name+"_count", h.labels, s.values, "", float64(s.count))
+	}
+}
+
+type funcMetric struct {
+	desc
+	f func() []Sample
+}
+
+func (m *funcMetric) write(buf *bytes.Buffer) {
+	samples := m.f()
+	sort.Slice(samples, func(i, j int) bool {
+		return seriesKey(samples[i].LabelValues) < seriesKey(samples[j].LabelValues)
+	})
+
+	m.writeHeader(buf)
+	for _, s := range samples {
+		m.checkLabels(s.LabelValues)
+		writeSample(buf, m.name, m.labels, s.LabelValues, "", s.Value)
+	}
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounterVec("http_requests_total", "Requests served.", "code", "class")
+	requests.Inc("200", "page")
+	requests.Inc("200", "page")
+	requests.Add(3, "404", "asset")
+
+	latency := r.NewHistogramVec("http_request_duration_seconds", "Request latency.", []float64{0.1, 1}, "class")
+	latency.Observe(0.05, "page")
+	latency.Observe(0.1, "page")
+	latency.Observe(2, "page")
+
+	r.NewGaugeFunc("cert_expiry_timestamp_seconds", "Certificate expiry.", func() []Sample {
+		return []Sample{
+			{LabelValues: []string{"www.example.com"}, Value: 1.5e9},
+			{LabelValues: []string{"example.com"}, Value: 1532131200},
+		}
+	}, "domain")
+	r.NewCounterFunc("escaped_total", "Help with \\ and\nnewline.", func() []Sample {
+		return []Sample{{LabelValues: []string{"a \"quoted\"\\path\n"}, Value: 1}}
+	}, "path")
+	r.NewCounterVec("unlabelled_total", "No labels.").Inc()
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatalf("want WriteTo() to return no error, got= %v", err)
+	}
+
+	expected := `# HELP cert_expiry_timestamp_seconds Certificate expiry.
+# TYPE cert_expiry_timestamp_seconds gauge
+cert_expiry_timestamp_seconds{domain="example.com"} 1532131200
+cert_expiry_timestamp_seconds{domain="www.example.com"} 1500000000
+# HELP escaped_total Help with \\ and\nnewline.
+# TYPE escaped_total counter
+escaped_total{path="a \"quoted\"\\path\n"} 1
+# HELP http_request_duration_seconds Request latency.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{class="page",le="0.1"} 2
+http_request_duration_seconds_bucket{class="page",le="1"} 2
+http_request_duration_seconds_bucket{class="page",le="+Inf"} 3
+http_request_duration_seconds_sum{class="page"} 2.15
+http_request_duration_seconds_count{class="page"} 3
+# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{code="200",class="page"} 2
+http_requests_total{code="404",class="asset"} 3
+# HELP unlabelled_total No labels.
+# TYPE unlabelled_total counter
+unlabelled_total 1
+`
+	if want, got := expected, buf.String(); want != got {
+		t.Errorf("wrong metrics\n  diff= %v", cmp.Diff(want, got))
+	}
+}
+
+func TestRegistryHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("hits_total", "Hits.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	resp := w.Result()
+
+	if want, got := ContentType, resp.Header.Get("Content-Type"); want != got {
+		t.Errorf("wrong Content-Type, want= %q, got= %q", want, got)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if want, got := "# HELP hits_total Hits.\n# TYPE hits_total counter\nhits_total 1\n", string(b); want != got {
+		t.Errorf("wrong body, want= %q, got= %q", want, got)
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	var testCases = []struct {
+		name string
+		f    func(r *Registry)
+	}{
+		{"duplicate", func(r *Registry) {
+			r.NewCounterVec("x", "")
+			r.NewCounterVec("x", "")
+		}},
+		{"wrong labels", func(r *Registry) {
+			r.NewCounterVec("x", "", "a").Inc()
+		}},
+		{"decreasing counter", func(r *Registry) {
+			r.NewCounterVec("x", "").Add(-1)
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("want a panic, got none")
+				}
+			}()
+			tc.f(NewRegistry())
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Format of access log lines.
+type Format string
+
+const (
+	Common   Format = "common"   // Common Log Format
+	Combined Format = "combined" // Common Log Format with referer and user agent
+	JSON     Format = "json"     // one JSON object per line
+)
+
+// clfTime is the time layout of the Common Log Format.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// entry is what is logged about a request.
+type entry struct {
+	Time      time.Time `json:"time"`
+	Remote    string    `json:"remote"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Duration  float64   `json:"duration_ms"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// NewHandler writes a line to out for every request served by next.
+// The client address is read from X-Forwarded-For for requests coming from
+// trustedProxies (IP addresses or CIDR ranges), e.g a reverse proxy.
+func NewHandler(next http.Handler, out io.Writer, format Format, trustedProxies ...string) (http.Handler, error) {
+	switch format {
+	case Common, Combined, JSON:
+	default:
+		return nil, errors.Errorf("unknown access log format %q, want one of common, combined or json", format)
+	}
+
+	trusted, err := parseNets(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex // keeps lines whole
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		e := entry{
+			Time:      start,
+			Remote:    clientIP(r, trusted),
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			Proto:     r.Proto,
+			Status:    rw.status,
+			Bytes:     rw.bytes,
+			Duration:  float64(time.Since(start)) / float64(time.Millisecond),
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+		}
+
+		line := e.format(format)
+		mu.Lock()
+		out.Write(line)
+		mu.Unlock()
+	}
+
+	return http.HandlerFunc(handler), nil
+}
+
+func (e entry) format(format Format) []byte {
+	if format == JSON {
+		b, _ := json.Marshal(e) // only made of strings and numbers
+		return append(b, '\n')
+	}
+
+	size := "-"
+	if e.Bytes > 0 {
+		size = fmt.Sprint(e.Bytes)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s - - [%s] %q %d %s",
+		e.Remote, e.Time.Format(clfTime), e.Method+" "+e.Path+" "+e.Proto, e.Status, size)
+	if format == Combined {
+		fmt.Fprintf(&buf, " %q %q", orDash(e.Referer), orDash(e.UserAgent))
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes()
+}
+
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+
+	return s
+}
+
+func parseNets(addrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(addrs))
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "/") {
+			if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+				addr += "/32"
+			} else {
+				addr += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, errors.Errorf("invalid trusted proxy %q, want an IP address or CIDR range", addr)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP returns the address of the client: the peer, unless it is a trusted proxy,
+// in which case it is the last address in X-Forwarded-For that isn't one.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr // e.g Unix socket
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		// Unix sockets have no address; only a local proxy can connect
+		if len(trusted) == 0 {
+			return orDash(host)
+		}
+	} else if !isTrusted(ip, trusted) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		fip := net.ParseIP(addr)
+		if fip == nil {
+			break
+		}
+		if !isTrusted(fip, trusted) || i == 0 {
+			return addr
+		}
+	}
+
+	return orDash(host)
+}
+
+// responseWriter records the status and size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,139 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+var notFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+})
+
+func TestNewHandler(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		format   Format
+		handler  http.Handler
+		url      string
+		expected string
+	}{
+		{
+			name:     "common",
+			format:   Common,
+			handler:  okHandler,
+			url:      "/2018/07/21/post?q=1",
+			expected: `^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [-+]\d{4}\] "GET /2018/07/21/post\?q=1 HTTP/1\.1" 200 2\n$`,
+		},
+		{
+			name:     "combined",
+			format:   Combined,
+			handler:  okHandler,
+			url:      "/",
+			expected: `^192\.0\.2\.1 - - \[.+\] "GET / HTTP/1\.1" 200 2 "https://example\.com/" "test-agent"\n$`,
+		},
+		{
+			name:     "no body",
+			format:   Common,
+			handler:  notFoundHandler,
+			url:      "/missing",
+			expected: `^192\.0\.2\.1 - - \[.+\] "GET /missing HTTP/1\.1" 404 -\n$`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			handler, err := NewHandler(tc.handler, &out, tc.format)
+			if err != nil {
+				t.Fatalf("want NewHandler() to return no error, got= %v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			r.Header.Set("Referer", "https://example.com/")
+			r.Header.Set("User-Agent", "test-agent")
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if want, got := tc.expected, out.String(); !regexp.MustCompile(want).MatchString(got) {
+				t.Errorf("wrong log line, want= %q, got= %q", want, got)
+			}
+		})
+	}
+}
+
+func TestNewHandlerJSON(t *testing.T) {
+	var out bytes.Buffer
+	handler, err := NewHandler(okHandler, &out, JSON)
+	if err != nil {
+		t.Fatalf("want NewHandler() to return no error, got= %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodHead, "/feed.xml", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	var e entry
+	if err := json.Unmarshal(out.Bytes(), &e); err != nil {
+		t.Fatalf("want a JSON line, got= %q (%v)", out.String(), err)
+	}
+	if want, got := (entry{Time: e.Time, Remote: "192.0.2.1", Method: "HEAD", Path: "/feed.xml", Proto: "HTTP/1.1", Status: 200, Bytes: 2, Duration: e.Duration, UserAgent: "test-agent"}), e; want != got {
+		t.Errorf("wrong entry, want= %+v, got= %+v", want, got)
+	}
+	if e.Time.IsZero() {
+		t.Errorf("want time to be set, got= %v", e.Time)
+	}
+}
+
+func TestNewHandlerInvalid(t *testing.T) {
+	if _, err := NewHandler(okHandler, &bytes.Buffer{}, "apache"); err == nil {
+		t.Errorf("want an error for an unknown format, got= nil")
+	}
+	if _, err := NewHandler(okHandler, &bytes.Buffer{}, Common, "10.0.0.0/33"); err == nil {
+		t.Errorf("want an error for an invalid trusted proxy, got= nil")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseNets([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatalf("want parseNets() to return no error, got= %v", err)
+	}
+
+	var testCases = []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{name: "direct", remoteAddr: "192.0.2.1:1234", expected: "192.0.2.1"},
+		{name: "untrusted peer", remoteAddr: "192.0.2.1:1234", forwarded: []string{"198.51.100.7"}, expected: "192.0.2.1"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:1234", forwarded: []string{"198.51.100.7"}, expected: "198.51.100.7"},
+		{name: "ipv6 proxy", remoteAddr: "[::1]:1234", forwarded: []string{"198.51.100.7"}, expected: "198.51.100.7"},
+		{name: "chain of proxies", remoteAddr: "10.0.0.2:1234", forwarded: []string{"203.0.113.9, 198.51.100.7", "10.0.0.3"}, expected: "198.51.100.7"},
+		{name: "all trusted", remoteAddr: "10.0.0.2:1234", forwarded: []string{"10.0.0.4, 10.0.0.3"}, expected: "10.0.0.4"},
+		{name: "garbage", remoteAddr: "10.0.0.2:1234", forwarded: []string{"unknown"}, expected: "10.0.0.2"},
+		{name: "no header", remoteAddr: "10.0.0.2:1234", expected: "10.0.0.2"},
+		{name: "unix socket", remoteAddr: "@", forwarded: []string{"198.51.100.7"}, expected: "198.51.100.7"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for _, f := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", f)
+			}
+
+			if want, got := tc.expected, clientIP(r, trusted); want != got {
+				t.Errorf("wrong client IP, want= %q, got= %q", want, got)
+			}
+		})
+	}
+}
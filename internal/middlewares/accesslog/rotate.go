@@ -0,0 +1,142 @@
+package accesslog
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// backupTime is the layout of the timestamp appended to rotated files.
+const backupTime = "20060102T150405.000"
+
+// Rotation decides when a RotatingFile starts a new file.
+// A zero MaxSize or Interval disables that trigger; a zero MaxBackups keeps every file.
+type Rotation struct {
+	MaxSize    int64         // bytes
+	Interval   time.Duration // age of the current file
+	MaxBackups int
+}
+
+// RotatingFile is an io.Writer appending to a file that is rotated according to a Rotation.
+// Rotated files are renamed to <path>.<timestamp>.
+type RotatingFile struct {
+	path     string
+	rotation Rotation
+	now      func() time.Time
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// OpenRotatingFile opens path for appending, creating it if needed.
+func OpenRotatingFile(path string, rotation Rotation) (*RotatingFile, error) {
+	rf := &RotatingFile{path: path, rotation: rotation, now: time.Now}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return errors.Wrapf(err, "cannot open %q", rf.path)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrapf(err, "cannot stat %q", rf.path)
+	}
+
+	rf.f, rf.size, rf.opened = f, stat.Size(), rf.now()
+	return nil
+}
+
+// Write appends b to the file, rotating it first if needed.
+func (rf *RotatingFile) Write(b []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return 0, errors.Errorf("%q is closed", rf.path)
+	}
+	if rf.shouldRotate(int64(len(b))) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.f.Write(b)
+	rf.size += int64(n)
+
+	return n, err
+}
+
+func (rf *RotatingFile) shouldRotate(n int64) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.rotation.MaxSize > 0 && rf.size+n > rf.rotation.MaxSize {
+		return true
+	}
+
+	return rf.rotation.Interval > 0 && rf.now().Sub(rf.opened) >= rf.rotation.Interval
+}
+
+func (rf *RotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return errors.Wrapf(err, "cannot close %q", rf.path)
+	}
+	rf.f = nil
+
+	backup := rf.path + "." + rf.now().Format(backupTime)
+	if err := os.Rename(rf.path, backup); err != nil {
+		return errors.Wrapf(err, "cannot rename %q to %q", rf.path, backup)
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+
+	return rf.removeOldBackups()
+}
+
+func (rf *RotatingFile) removeOldBackups() error {
+	if rf.rotation.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(rf.path + ".*")
+	if err != nil {
+		return errors.Wrapf(err, "cannot list backups of %q", rf.path)
+	}
+	sort.Strings(backups) // timestamps sort chronologically
+	for len(backups) > rf.rotation.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return errors.Wrapf(err, "cannot remove %q", backups[0])
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// Close closes the current file.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+
+	return errors.Wrapf(err, "cannot close %q", rf.path)
+}
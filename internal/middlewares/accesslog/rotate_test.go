@@ -0,0 +1,110 @@
+package accesslog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("cannot list %q: %v", dir, err)
+	}
+	sort.Strings(files)
+	contents := map[string]string{}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatalf("cannot read %q: %v", f, err)
+		}
+		contents[filepath.Base(f)] = string(b)
+	}
+
+	return contents
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "accesslog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Date(2018, 7, 21, 10, 0, 0, 0, time.UTC)
+
+	rf, err := OpenRotatingFile(filepath.Join(dir, "access.log"), Rotation{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("want OpenRotatingFile() to return no error, got= %v", err)
+	}
+	defer rf.Close()
+	rf.now = func() time.Time { return now }
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatalf("want Write() to return no error, got= %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	want := map[string]string{
+		"access.log":                     "line 4\n",
+		"access.log.20180721T100002.000": "line 2\n",
+		"access.log.20180721T100003.000": "line 3\n",
+	}
+	got := readDir(t, dir)
+	if len(want) != len(got) {
+		t.Fatalf("wrong files, want= %v, got= %v", want, got)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("wrong content of %q, want= %q, got= %q", name, content, got[name])
+		}
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "accesslog")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "access.log")
+	if err := ioutil.WriteFile(fname, []byte("old\n"), 0640); err != nil {
+		t.Fatalf("cannot write %q: %v", fname, err)
+	}
+	now := time.Date(2018, 7, 21, 10, 0, 0, 0, time.UTC)
+
+	rf, err := OpenRotatingFile(fname, Rotation{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("want OpenRotatingFile() to return no error, got= %v", err)
+	}
+	rf.now = func() time.Time { return now }
+	rf.opened = now
+
+	rf.Write([]byte("a\n"))
+	now = now.Add(time.Hour)
+	rf.Write([]byte("b\n"))
+	if err := rf.Close(); err != nil {
+		t.Fatalf("want Close() to return no error, got= %v", err)
+	}
+
+	got := readDir(t, dir)
+	if want, got := "b\n", got["access.log"]; want != got {
+		t.Errorf("wrong current file, want= %q, got= %q", want, got)
+	}
+	if want, got := "old\na\n", got["access.log.20180721T110000.000"]; want != got {
+		t.Errorf("wrong rotated file, want= %q, got= %q", want, got)
+	}
+
+	if _, err := rf.Write([]byte("c\n")); err == nil {
+		t.Errorf("want Write() after Close() to return an error, got= nil")
+	}
+	if _, err := os.Stat(fname); err != nil {
+		t.Errorf("want %q to exist, got= %v", fname, err)
+	}
+}
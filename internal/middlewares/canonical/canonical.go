@@ -0,0 +1,112 @@
+// Package canonical redirects requests to the one URL of each page,
+// so that search engines don't index duplicates.
+package canonical
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Redirector redirects some requests, e.g *redirect.Handler.
+type Redirector interface {
+	// TryRedirect answers r with a redirection and returns true, if it has one for r.
+	TryRedirect(w http.ResponseWriter, r *http.Request) bool
+}
+
+// NewHandler permanently redirects GET and HEAD requests to their canonical URL:
+// on host (unless empty), and without .html, /index.html, trailing or duplicate slashes.
+// The query is kept. Other requests are passed to next.
+//
+// Redirections of rd, if any, are tried on the requested URL then on the canonical one,
+// so that there is a single redirection.
+func NewHandler(next http.Handler, host string, rd Redirector) (http.Handler, error) {
+	if strings.ContainsAny(host, "/:@ ") {
+		return nil, errors.Errorf("canonical host must be a host name, got %q", host)
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		target, changed := canonicalURL(r, host)
+		if !changed {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if rd != nil {
+			rw := w
+			if len(target.Host) > 0 {
+				rw = hostWriter{ResponseWriter: w, host: target.Host}
+			}
+			if rd.TryRedirect(rw, r) {
+				return
+			}
+			cr := *r
+			u := *r.URL
+			cr.URL = &u
+			cr.URL.Path, cr.URL.RawPath = target.Path, ""
+			if len(target.Host) > 0 {
+				cr.Host, cr.URL.Host = target.Host, ""
+			}
+			if rd.TryRedirect(rw, &cr) {
+				return
+			}
+		}
+
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	}
+
+	return http.HandlerFunc(handler), nil
+}
+
+// canonicalURL returns the canonical URL for r: a path and query, with a host
+// (and no scheme, to keep the one of the request) only if it must change.
+func canonicalURL(r *http.Request, host string) (url.URL, bool) {
+	u := url.URL{Path: canonicalPath(r.URL.Path), RawQuery: r.URL.RawQuery}
+	changed := u.Path != r.URL.Path
+
+	reqHost := r.Host
+	if h, _, err := net.SplitHostPort(reqHost); err == nil {
+		reqHost = h
+	}
+	if len(host) > 0 && !strings.EqualFold(reqHost, host) {
+		u.Host, changed = host, true
+	}
+
+	return u, changed
+}
+
+// canonicalPath returns the path of the page served for p.
+// /dir/index.html is kept, as /dir would be dir.html.
+func canonicalPath(p string) string {
+	clean := path.Clean("/" + p)
+	switch {
+	case clean == "/index.html":
+		return "/"
+	case path.Ext(clean) == ".html" && path.Base(clean) != "index.html":
+		return strings.TrimSuffix(clean, ".html")
+	}
+
+	return clean
+}
+
+// hostWriter puts redirections to a path on host, which would otherwise stay on
+// the requested one and take another hop.
+type hostWriter struct {
+	http.ResponseWriter
+	host string
+}
+
+func (w hostWriter) WriteHeader(status int) {
+	if loc := w.Header().Get("Location"); strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+		w.Header().Set("Location", "//"+w.host+loc)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
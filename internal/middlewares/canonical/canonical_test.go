@@ -0,0 +1,101 @@
+package canonical
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+// fakeRedirector redirects the paths (and hosts) it knows.
+type fakeRedirector map[string]string
+
+func (rd fakeRedirector) TryRedirect(w http.ResponseWriter, r *http.Request) bool {
+	for _, k := range []string{r.Host + r.URL.Path, r.URL.Path} {
+		if to, exist := rd[k]; exist {
+			http.Redirect(w, r, to, http.StatusFound)
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestNewHandler(t *testing.T) {
+	rd := fakeRedirector{
+		"/old-post":                "/2018/07/21/post",
+		"www.example.com/old-page": "https://example.com/page",
+	}
+	handler, err := NewHandler(okHandler, "example.com", rd)
+	if err != nil {
+		t.Fatalf("want NewHandler() to return no error, got= %v", err)
+	}
+
+	var testCases = []struct {
+		name             string
+		method           string
+		url              string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"canonical", http.MethodGet, "https://example.com/2018/07/21/post", http.StatusOK, ""},
+		{"root", http.MethodGet, "https://example.com/", http.StatusOK, ""},
+		{"asset", http.MethodGet, "https://example.com/builtin.css", http.StatusOK, ""},
+		{"dir index", http.MethodGet, "https://example.com/projects/index.html", http.StatusOK, ""},
+		{"port", http.MethodGet, "https://example.com:8443/about", http.StatusOK, ""},
+		{"html", http.MethodGet, "https://example.com/about.html", http.StatusMovedPermanently, "/about"},
+		{"index", http.MethodGet, "https://example.com/index.html", http.StatusMovedPermanently, "/"},
+		{"trailing slash", http.MethodGet, "https://example.com/2018/07/21/post/", http.StatusMovedPermanently, "/2018/07/21/post"},
+		{"double slashes", http.MethodGet, "https://example.com//evil.com/", http.StatusMovedPermanently, "/evil.com"},
+		{"dot segments", http.MethodGet, "https://example.com/tags/../about", http.StatusMovedPermanently, "/about"},
+		{"query", http.MethodHead, "https://example.com/about.html?ref=rss", http.StatusMovedPermanently, "/about?ref=rss"},
+		{"host", http.MethodGet, "https://www.example.com/about", http.StatusMovedPermanently, "//example.com/about"},
+		{"host and path", http.MethodGet, "http://www.example.com/about.html?x=1", http.StatusMovedPermanently, "//example.com/about?x=1"},
+		{"other methods", http.MethodOptions, "https://www.example.com/about.html", http.StatusOK, ""},
+		{"redirect as requested", http.MethodGet, "https://www.example.com/old-page", http.StatusFound, "https://example.com/page"},
+		{"redirect canonical", http.MethodGet, "https://example.com/old-post.html", http.StatusFound, "/2018/07/21/post"},
+		{"redirect canonical host", http.MethodGet, "https://www.example.com/old-post.html", http.StatusFound, "//example.com/2018/07/21/post"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tc.method, tc.url, nil))
+			resp := w.Result()
+
+			if want, got := tc.expectedStatus, resp.StatusCode; want != got {
+				t.Errorf("wrong status, want= %v, got= %v", want, got)
+			}
+			if want, got := tc.expectedLocation, resp.Header.Get("Location"); want != got {
+				t.Errorf("wrong Location, want= %q, got= %q", want, got)
+			}
+		})
+	}
+}
+
+func TestNewHandlerNoHost(t *testing.T) {
+	handler, err := NewHandler(okHandler, "", nil)
+	if err != nil {
+		t.Fatalf("want NewHandler() to return no error, got= %v", err)
+	}
+
+	for url, expected := range map[string]string{
+		"https://www.example.com/about":      "",
+		"https://www.example.com/about.html": "/about",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if want, got := expected, w.Result().Header.Get("Location"); want != got {
+			t.Errorf("wrong Location for %q, want= %q, got= %q", url, want, got)
+		}
+	}
+}
+
+func TestNewHandlerInvalid(t *testing.T) {
+	if _, err := NewHandler(okHandler, "https://example.com", nil); err == nil {
+		t.Errorf("want an error for a URL as host, got none")
+	}
+}
@@ -0,0 +1,112 @@
+package redirect
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// fileRedirect is a redirection in a YAML redirects file.
+type fileRedirect struct {
+	From   string `yaml:"from"`
+	To     string `yaml:"to"`
+	Status int    `yaml:"status"`
+}
+
+// ParseFile reads redirections from fname: a YAML list of from, to and status
+// if it ends in .yaml or .yml, otherwise one "from to [status]" per line, as in
+// Netlify's _redirects, with # comments. Redirections are checked by NewHandler.
+func ParseFile(fname string) (Redirections, error) {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read redirects file %q", fname)
+	}
+
+	switch filepath.Ext(fname) {
+	case ".yaml", ".yml":
+		return parseYAML(fname, b)
+	}
+	return parseText(fname, b)
+}
+
+func parseYAML(fname string, b []byte) (Redirections, error) {
+	var frs []fileRedirect
+	if err := yaml.UnmarshalStrict(b, &frs); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse redirects file %q", fname)
+	}
+
+	rds := make(Redirections, 0, len(frs))
+	for i, fr := range frs {
+		rd, err := ParseRedirection(fr.From, fr.To, fr.Status)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: redirect %d", fname, i+1)
+		}
+		rds = append(rds, rd)
+	}
+
+	return rds, nil
+}
+
+func parseText(fname string, b []byte) (Redirections, error) {
+	var rds Redirections
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 1 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 3 {
+			return nil, errors.Errorf("%s:%d: want \"from to [status]\", got %q", fname, line, scanner.Text())
+		}
+		if len(fields) < 2 {
+			return nil, errors.Errorf("%s:%d: missing URL to redirect to", fname, line)
+		}
+
+		status := 0
+		if len(fields) == 3 {
+			var err error
+			if status, err = strconv.Atoi(fields[2]); err != nil {
+				return nil, errors.Errorf("%s:%d: status must be a number, got %q", fname, line, fields[2])
+			}
+		}
+
+		rd, err := ParseRedirection(fields[0], fields[1], status)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d", fname, line)
+		}
+		rds = append(rds, rd)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "cannot read redirects file %q", fname)
+	}
+
+	return rds, nil
+}
+
+// ParseRedirection parses the URLs of a redirection, and checks its status (0 for 301).
+func ParseRedirection(fromStr, toStr string, status int) (Redirection, error) {
+	if len(fromStr) < 1 || len(toStr) < 1 {
+		return Redirection{}, errors.Errorf("both from and to are required")
+	}
+
+	from, err := url.Parse(fromStr)
+	if err != nil {
+		return Redirection{}, errors.Errorf("URL to redirect from, %q, is not valid", fromStr)
+	}
+	to, err := url.Parse(toStr)
+	if err != nil {
+		return Redirection{}, errors.Errorf("URL to redirect to, %q, is not valid", toStr)
+	}
+	if status != 0 && !statuses[status] {
+		return Redirection{}, errors.Errorf("status must be 301, 302, 307 or 308, got %d", status)
+	}
+
+	return Redirection{FromURL: *from, ToURL: *to, Status: status}, nil
+}
@@ -0,0 +1,99 @@
+package redirect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseFile(t *testing.T) {
+	var testCases = []struct {
+		fname    string
+		expected Redirections
+	}{
+		{
+			"testdata/_redirects",
+			Redirections{
+				{FromURL: mustParse("/old-blog/*"), ToURL: mustParse("/2018/:splat"), Status: 302},
+				{FromURL: mustParse("/posts/:year/:slug"), ToURL: mustParse("/:year/:slug")},
+				{FromURL: mustParse("https://www.example.com/*"), ToURL: mustParse("https://example.com/*"), Status: 308},
+				{FromURL: mustParse("/feed"), ToURL: mustParse("/feed.atom")},
+			},
+		},
+		{
+			"testdata/redirects.yaml",
+			Redirections{
+				{FromURL: mustParse("/old-blog/*"), ToURL: mustParse("/2018/:splat"), Status: 302},
+				{FromURL: mustParse("https://www.example.com/*"), ToURL: mustParse("https://example.com/*"), Status: 308},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.fname, func(t *testing.T) {
+			rds, err := ParseFile(tc.fname)
+			if err != nil {
+				t.Fatalf("want ParseFile() to return no error, got= %v", err)
+			}
+			if want, got := tc.expected, rds; !cmp.Equal(want, got) {
+				t.Errorf("wrong redirections\n  diff= %v", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	var testCases = []struct {
+		fname    string
+		expected string
+	}{
+		{"testdata/missing", `cannot read redirects file "testdata/missing"`},
+		{"testdata/invalid_status", `testdata/invalid_status:2: status must be 301, 302, 307 or 308, got 200`},
+		{"testdata/invalid_fields", `testdata/invalid_fields:1: missing URL to redirect to`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.fname, func(t *testing.T) {
+			_, err := ParseFile(tc.fname)
+			if err == nil {
+				t.Fatalf("want ParseFile() to return an error, got none")
+			}
+			if want, got := tc.expected, err.Error(); !strings.Contains(got, want) {
+				t.Errorf("wrong error, want it to contain %q, got= %q", want, got)
+			}
+		})
+	}
+}
+
+func TestHandlerFileRedirects(t *testing.T) {
+	rds, err := ParseFile("testdata/_redirects")
+	if err != nil {
+		t.Fatalf("want ParseFile() to return no error, got= %v", err)
+	}
+	handler, err := NewHandler(nil, rds, "example.com", "www.example.com")
+	if err != nil {
+		t.Fatalf("want NewHandler() to return no error, got= %v", err)
+	}
+
+	var testCases = []struct {
+		host, path, expected string
+	}{
+		{"example.com", "/old-blog/07/21/post", "/2018/07/21/post"},
+		{"www.example.com", "/feed", "/feed.atom"},
+		{"www.example.com", "/old-blog/post", "https://example.com/old-blog/post"},
+	}
+
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		r.Host = tc.host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if want, got := tc.expected, w.Result().Header.Get("Location"); want != got {
+			t.Errorf("wrong Location for %s%s, want= %q, got= %q", tc.host, tc.path, want, got)
+		}
+	}
+}
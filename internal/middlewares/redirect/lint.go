@@ -0,0 +1,213 @@
+package redirect
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ProblemKind is the kind of a Problem found by Lint.
+type ProblemKind string
+
+const (
+	Loop          ProblemKind = "loop"           // redirects back to itself, eventually
+	Chain         ProblemKind = "chain"          // more than one hop, e.g A → B → C instead of A → C
+	Duplicate     ProblemKind = "duplicate"      // same FromURL as another, only one is used
+	MissingTarget ProblemKind = "missing target" // redirects to a path that doesn't exist
+)
+
+// Problem is an issue with the Redirection at Index, found by Lint.
+type Problem struct {
+	Kind    ProblemKind
+	Index   int
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("redirect %d: %s: %s", p.Index+1, p.Kind, p.Message)
+}
+
+// maxHops bounds how far Lint follows redirections, as patterns can grow paths forever.
+const maxHops = 10
+
+// Lint checks rds as NewHandler does, then finds loops, chains of more than one hop,
+// duplicate FromURLs and, if exists is not nil, redirections to paths of the domains
+// for which exists returns false. Captures of patterns are followed as "x".
+func Lint(rds Redirections, domains []string, exists func(path string) bool) ([]Problem, error) {
+	set, err := newRuleSet(rds, domains)
+	if err != nil {
+		return nil, err
+	}
+
+	problems, unused := set.duplicates()
+	for i, ru := range set.rules {
+		if unused[i] {
+			continue
+		}
+		if p, found := set.follow(i, ru, domains); found {
+			problems = append(problems, p)
+			continue
+		}
+		if exists != nil && !ru.hasCaptures() && servesTarget(ru.target, domains) && !exists(requestPath(ru.target)) {
+			problems = append(problems, Problem{Kind: MissingTarget, Index: i,
+				Message: fmt.Sprintf("%s redirects to %s, which doesn't exist", ru.from, ru.to)})
+		}
+	}
+
+	return problems, nil
+}
+
+// duplicates finds rules never used, as another with the same FromURL takes precedence:
+// the last exact one, or the first pattern.
+func (set *ruleSet) duplicates() ([]Problem, map[int]bool) {
+	var problems []Problem
+	unused := map[int]bool{}
+	seen := map[string]int{}
+	for i, ru := range set.rules {
+		k := ru.key()
+		prev, exist := seen[k]
+		switch {
+		case !exist:
+			seen[k] = i
+		case len(ru.segments) > 0:
+			unused[i] = true
+			problems = append(problems, Problem{Kind: Duplicate, Index: i,
+				Message: fmt.Sprintf("%s never matches, redirect %d (%s) matches first", ru.from, prev+1, set.rules[prev].from)})
+		default:
+			unused[prev] = true
+			seen[k] = i
+			problems = append(problems, Problem{Kind: Duplicate, Index: prev,
+				Message: fmt.Sprintf("%s is overwritten by redirect %d (%s)", set.rules[prev].from, i+1, ru.from)})
+		}
+	}
+
+	return problems, unused
+}
+
+// key identifies what a rule matches: captures are anonymous, as /:a and /:b match the same.
+func (ru *rule) key() string {
+	if len(ru.segments) < 1 {
+		return redirectKey(ru.fromURL.Host, ru.fromURL.Path, ru.fromURL.RawQuery)
+	}
+
+	segments := make([]string, 0, len(ru.segments))
+	for _, seg := range ru.segments {
+		if strings.HasPrefix(seg, ":") {
+			seg = ":"
+		}
+		segments = append(segments, seg)
+	}
+
+	return fmt.Sprintf("%t %s %s", ru.wildcard, ru.host, strings.Join(segments, "/"))
+}
+
+func (ru *rule) hasCaptures() bool {
+	for _, seg := range strings.Split(ru.target.Path, "/") {
+		if seg == "*" || strings.HasPrefix(seg, ":") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestPath returns the path requested when following a redirection to u,
+// e.g. / for https://example.com.
+func requestPath(u url.URL) string {
+	if len(u.Path) < 1 {
+		return "/"
+	}
+
+	return u.Path
+}
+
+// follow redirects a request matched by ru until it isn't, to find loops and chains.
+func (set *ruleSet) follow(i int, ru *rule, domains []string) (Problem, bool) {
+	cur := ru.sample(domains)
+	if matched, _, _ := set.find(cur.Host, cur.Path, cur.RawQuery); matched != ru {
+		return Problem{}, false // another rule matches first
+	}
+
+	hops := []string{display(cur)}
+	seen := map[string]bool{hops[0]: true}
+	for len(hops) <= maxHops {
+		_, target, ok := set.find(cur.Host, cur.Path, cur.RawQuery)
+		if !ok {
+			break
+		}
+
+		next := cur
+		if len(target.Host) > 0 {
+			next.Host = target.Host
+		}
+		next.Path, next.RawQuery = requestPath(target), target.RawQuery
+		hops = append(hops, display(next))
+		if seen[display(next)] {
+			return Problem{Kind: Loop, Index: i, Message: strings.Join(hops, " → ")}, true
+		}
+		if !servesTarget(target, domains) {
+			break
+		}
+		seen[display(next)] = true
+		cur = next
+	}
+
+	switch {
+	case len(hops) > maxHops:
+		return Problem{Kind: Loop, Index: i,
+			Message: fmt.Sprintf("more than %d hops: %s ...", maxHops, strings.Join(hops[:3], " → "))}, true
+	case len(hops) > 2:
+		return Problem{Kind: Chain, Index: i,
+			Message: fmt.Sprintf("%s, redirect to %s directly", strings.Join(hops, " → "), hops[len(hops)-1])}, true
+	}
+
+	return Problem{}, false
+}
+
+// sample returns a request matched by ru, with captures as "x".
+func (ru *rule) sample(domains []string) url.URL {
+	u := url.URL{Host: ru.fromURL.Host, Path: ru.fromURL.Path, RawQuery: ru.fromURL.RawQuery}
+	if len(ru.segments) > 0 {
+		u.Path = ru.path()
+	}
+	switch {
+	case ru.wildcard:
+		u.Host = "x" + ru.host
+	case len(u.Host) < 1 && len(domains) > 0:
+		u.Host = domains[0]
+	}
+
+	return u
+}
+
+// path of a pattern with captures as "x".
+func (ru *rule) path() string {
+	segments := make([]string, 0, len(ru.segments))
+	for _, seg := range ru.segments {
+		if seg == "*" || strings.HasPrefix(seg, ":") {
+			seg = "x"
+		}
+		segments = append(segments, seg)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// servesTarget returns true if target is relative or on one of domains.
+func servesTarget(target url.URL, domains []string) bool {
+	if len(target.Host) < 1 {
+		return true
+	}
+	for _, d := range domains {
+		if target.Host == d {
+			return true
+		}
+	}
+
+	return false
+}
+
+func display(u url.URL) string {
+	u.Scheme = ""
+	return strings.TrimPrefix(u.String(), "//")
+}
@@ -0,0 +1,120 @@
+package redirect
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	domains := []string{"example.com", "www.example.com"}
+	exists := func(path string) bool {
+		return path == "/" || path == "/about" || path == "/2018/07/21/post"
+	}
+
+	var testCases = []struct {
+		name     string
+		rds      Redirections
+		expected []Problem
+	}{
+		{
+			name: "no problems",
+			rds: Redirections{
+				{FromURL: mustParse("https://www.example.com/*"), ToURL: mustParse("https://example.com/*")},
+				{FromURL: mustParse("/old-about"), ToURL: mustParse("/about")},
+				{FromURL: mustParse("/twitter"), ToURL: mustParse("https://twitter.com/example")},
+				{FromURL: mustParse("/search?q=go"), ToURL: mustParse("/about")},
+				{FromURL: mustParse("/search"), ToURL: mustParse("/")},
+				{FromURL: mustParse("/home"), ToURL: mustParse("https://example.com")},
+			},
+		},
+		{
+			name: "loop",
+			rds: Redirections{
+				{FromURL: mustParse("/a"), ToURL: mustParse("/b")},
+				{FromURL: mustParse("/b"), ToURL: mustParse("https://example.com/a")},
+				{FromURL: mustParse("/self"), ToURL: mustParse("/self")},
+			},
+			expected: []Problem{
+				{Kind: Loop, Index: 0, Message: "example.com/a → example.com/b → example.com/a"},
+				{Kind: Loop, Index: 1, Message: "example.com/b → example.com/a → example.com/b"},
+				{Kind: Loop, Index: 2, Message: "example.com/self → example.com/self"},
+			},
+		},
+		{
+			name: "pattern loop",
+			rds: Redirections{
+				{FromURL: mustParse("/a/*"), ToURL: mustParse("/a/b/*")},
+			},
+			expected: []Problem{
+				{Kind: Loop, Index: 0, Message: "more than 10 hops: example.com/a/x → example.com/a/b/x → example.com/a/b/b/x ..."},
+			},
+		},
+		{
+			name: "chain",
+			rds: Redirections{
+				{FromURL: mustParse("/old-blog/*"), ToURL: mustParse("/blog/*")},
+				{FromURL: mustParse("/blog/:slug"), ToURL: mustParse("/2018/07/21/:slug")},
+				{FromURL: mustParse("/me"), ToURL: mustParse("https://www.example.com/about")},
+				{FromURL: mustParse("https://www.example.com/about"), ToURL: mustParse("https://example.com/about")},
+				{FromURL: mustParse("/start"), ToURL: mustParse("https://www.example.com")},
+				{FromURL: mustParse("https://www.example.com/"), ToURL: mustParse("https://example.com/")},
+			},
+			expected: []Problem{
+				{Kind: Chain, Index: 0, Message: "example.com/old-blog/x → example.com/blog/x → example.com/2018/07/21/x, redirect to example.com/2018/07/21/x directly"},
+				{Kind: Chain, Index: 2, Message: "example.com/me → www.example.com/about → example.com/about, redirect to example.com/about directly"},
+				{Kind: Chain, Index: 4, Message: "example.com/start → www.example.com/ → example.com/, redirect to example.com/ directly"},
+			},
+		},
+		{
+			name: "duplicates",
+			rds: Redirections{
+				{FromURL: mustParse("http://example.com/a"), ToURL: mustParse("/about")},
+				{FromURL: mustParse("https://example.com/a"), ToURL: mustParse("/")},
+				{FromURL: mustParse("/posts/:year/:slug"), ToURL: mustParse("/:year/:slug")},
+				{FromURL: mustParse("/posts/:y/:s"), ToURL: mustParse("/")},
+			},
+			expected: []Problem{
+				{Kind: Duplicate, Index: 0, Message: "http://example.com/a is overwritten by redirect 2 (https://example.com/a)"},
+				{Kind: Duplicate, Index: 3, Message: "/posts/:y/:s never matches, redirect 3 (/posts/:year/:slug) matches first"},
+			},
+		},
+		{
+			name: "missing target",
+			rds: Redirections{
+				{FromURL: mustParse("/me"), ToURL: mustParse("/resume")},
+				{FromURL: mustParse("/old/*"), ToURL: mustParse("/new/*")},
+				{FromURL: mustParse("/other"), ToURL: mustParse("https://other.example.org/missing")},
+			},
+			expected: []Problem{
+				{Kind: MissingTarget, Index: 0, Message: "/me redirects to /resume, which doesn't exist"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problems, err := Lint(tc.rds, domains, exists)
+			if err != nil {
+				t.Fatalf("want Lint() to return no error, got= %v", err)
+			}
+			if want, got := tc.expected, problems; !cmp.Equal(want, got) {
+				t.Errorf("wrong problems\n  diff= %v", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestLintInvalid(t *testing.T) {
+	rds := Redirections{{FromURL: mustParse("https://example.org/"), ToURL: mustParse("/")}}
+	if _, err := Lint(rds, []string{"example.com"}, nil); err == nil {
+		t.Errorf("want Lint() to return an error, got none")
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	p := Problem{Kind: Chain, Index: 2, Message: "a → b → c"}
+	if want, got := "redirect 3: chain: a → b → c", p.String(); want != got {
+		t.Errorf("wrong string, want= %q, got= %q", want, got)
+	}
+}
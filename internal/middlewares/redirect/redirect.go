@@ -5,17 +5,40 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 )
 
+// Redirection redirects requests for FromURL to ToURL, with Status (301 if 0).
+//
+// FromURL can be a pattern:
+//   - its host can start with "*." to match any subdomain, e.g *.example.com
+//   - a path segment ":name" matches any segment, e.g /posts/:year/:slug
+//   - a last path segment "*" matches the rest of the path, e.g /old-blog/*
+//
+// Captures are substituted in the path of ToURL, e.g /:year/:slug or /2018/*.
+// The query of requests is passed to ToURL, unless FromURL has a query,
+// in which case only requests with that exact query match.
 type Redirection struct {
 	FromURL, ToURL url.URL
+	Status         int
 }
 
 type Redirections []Redirection
 
+// statuses are the allowed redirection status codes.
+var statuses = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
 func (rds *Redirections) String() string {
 	if len(*rds) < 1 {
 		return "{}"
@@ -25,13 +48,18 @@ func (rds *Redirections) String() string {
 	buf.WriteString("{")
 	for _, rd := range *rds {
 		from, to := rd.FromURL, rd.ToURL
-		buf.WriteString(fmt.Sprintf("%v => %v, ", from.String(), to.String()))
+		if rd.Status == 0 || rd.Status == http.StatusMovedPermanently {
+			buf.WriteString(fmt.Sprintf("%v => %v, ", from.String(), to.String()))
+		} else {
+			buf.WriteString(fmt.Sprintf("%v => %v => %d, ", from.String(), to.String(), rd.Status))
+		}
 	}
 	buf.WriteString("}")
 
 	return buf.String()
 }
 
+// Set parses comma-separated from=>to or from=>to=>status redirections.
 func (rds *Redirections) Set(strs string) error {
 	if len(strs) < 1 {
 		return nil
@@ -41,7 +69,7 @@ func (rds *Redirections) Set(strs string) error {
 	res := make([]Redirection, 0, len(pairs))
 	for _, str := range pairs {
 		parts := strings.Split(str, "=>")
-		if len(parts) != 2 {
+		if len(parts) != 2 && len(parts) != 3 {
 			return errors.Errorf("%q is not a valid redirection", str)
 		}
 
@@ -53,50 +81,346 @@ func (rds *Redirections) Set(strs string) error {
 		if err != nil {
 			return errors.Errorf("URL to redirect to, %q, is not valid", parts[1])
 		}
+		rd := Redirection{FromURL: *from, ToURL: *to}
+		if len(parts) == 3 {
+			if rd.Status, err = strconv.Atoi(parts[2]); err != nil || !statuses[rd.Status] {
+				return errors.Errorf("status of redirection %q must be 301, 302, 307 or 308", str)
+			}
+		}
 
-		res = append(res, Redirection{FromURL: *from, ToURL: *to})
+		res = append(res, rd)
 	}
 
 	*rds = res
 	return nil
 }
 
-func NewHandler(next http.Handler, rds Redirections, domains ...string) (http.Handler, error) {
-	servable := make(map[string]struct{}, len(domains))
-	for _, d := range domains {
-		servable[d] = struct{}{}
+// Handler redirects requests matching a Redirection, and passes the others to next.
+//
+// Redirections are tried in order of precedence:
+//  1. exact host, path and query
+//  2. exact host and path, whatever the query
+//  3. patterns: exact hosts, then wildcard ones, then none; then the most literal
+//     path segments, then without "*" before with it, then in the order given.
+//
+// A FromURL without host matches the path on every domain, after the ones with a host.
+type Handler struct {
+	next    http.Handler
+	domains []string
+
+	mu  sync.Mutex   // serializes reloads
+	set atomic.Value // *ruleSet
+}
+
+type ruleSet struct {
+	exactQuery map[string]*rule // by host+path?query
+	exactPath  map[string]*rule // by host+path
+	patterns   []*rule
+	rules      []*rule // in the order given
+}
+
+type rule struct {
+	from, to string
+	status   int
+	fromURL  url.URL
+	target   url.URL
+
+	// patterns only
+	host     string   // exact, or the suffix of a wildcard host, e.g .example.com; "" for any
+	wildcard bool     // host starts with *.
+	segments []string // of the path, ":name" and a last "*" capture
+	literals int      // number of literal segments
+	rest     bool     // last segment is "*"
+
+	hits uint64 // atomic
+}
+
+// Hit is the number of requests redirected by a Redirection.
+type Hit struct {
+	From, To string
+	Count    uint64
+}
+
+func NewHandler(next http.Handler, rds Redirections, domains ...string) (*Handler, error) {
+	h := &Handler{next: next, domains: domains}
+	if err := h.Reload(rds); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Reload replaces the redirections at once: a request is redirected either by
+// the previous ones or by rds. If rds is not valid, the previous ones are kept.
+// Hits of redirections in both are carried over.
+func (h *Handler) Reload(rds Redirections) error {
+	set, err := newRuleSet(rds, h.domains)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if prev, ok := h.set.Load().(*ruleSet); ok {
+		hits := make(map[string]uint64, len(prev.rules))
+		for _, ru := range prev.rules {
+			hits[ru.from+" => "+ru.to] += atomic.LoadUint64(&ru.hits)
+		}
+		for _, ru := range set.rules {
+			k := ru.from + " => " + ru.to
+			ru.hits = hits[k]
+			delete(hits, k)
+		}
 	}
+	h.set.Store(set)
+
+	return nil
+}
 
-	index := make(map[string]string, len(rds))
+func newRuleSet(rds Redirections, domains []string) (*ruleSet, error) {
+	set := &ruleSet{
+		exactQuery: make(map[string]*rule, len(rds)),
+		exactPath:  make(map[string]*rule, len(rds)),
+	}
 	for _, rd := range rds {
-		if !(rd.FromURL.Scheme == "" || rd.FromURL.Scheme == "http" || rd.FromURL.Scheme == "https") {
-			return nil, errors.Errorf("cannot redirect from URL with %s scheme", rd.FromURL.Scheme)
+		ru, err := newRule(rd, domains)
+		if err != nil {
+			return nil, err
 		}
-		if _, exist := servable[rd.FromURL.Host]; !exist {
-			return nil, errors.Errorf("cannot redirect from %v, not serving the domain", rd.FromURL.String())
+		set.rules = append(set.rules, ru)
+
+		switch {
+		case len(ru.segments) > 0:
+			set.patterns = append(set.patterns, ru)
+		case len(rd.FromURL.RawQuery) > 0:
+			set.exactQuery[redirectKey(rd.FromURL.Host, rd.FromURL.Path, rd.FromURL.RawQuery)] = ru
+		default:
+			set.exactPath[rd.FromURL.Host+rd.FromURL.Path] = ru
 		}
+	}
 
-		k := redirectKey(rd.FromURL.Host, rd.FromURL.Path, rd.FromURL.RawQuery)
-		index[k] = rd.ToURL.String()
+	sort.SliceStable(set.patterns, func(i, j int) bool {
+		a, b := set.patterns[i], set.patterns[j]
+		if a.hostRank() != b.hostRank() {
+			return a.hostRank() < b.hostRank()
+		}
+		if a.literals != b.literals {
+			return a.literals > b.literals
+		}
+		return !a.rest && b.rest
+	})
+
+	return set, nil
+}
+
+// hostRank orders patterns by host: exact, wildcard, then any.
+func (ru *rule) hostRank() int {
+	switch {
+	case ru.wildcard:
+		return 1
+	case len(ru.host) < 1:
+		return 2
 	}
 
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		host, path := r.URL.Host, r.URL.Path
-		if len(r.URL.Host) < 1 {
-			host = r.Host
+	return 0
+}
+
+func newRule(rd Redirection, domains []string) (*rule, error) {
+	from := rd.FromURL
+	if !(from.Scheme == "" || from.Scheme == "http" || from.Scheme == "https") {
+		return nil, errors.Errorf("cannot redirect from URL with %s scheme", from.Scheme)
+	}
+
+	ru := &rule{from: from.String(), to: rd.ToURL.String(), status: rd.Status, fromURL: from, target: rd.ToURL, host: from.Host}
+	if ru.status == 0 {
+		ru.status = http.StatusMovedPermanently
+	}
+	if !statuses[ru.status] {
+		return nil, errors.Errorf("cannot redirect from %v with status %d, want 301, 302, 307 or 308", ru.from, ru.status)
+	}
+
+	if strings.HasPrefix(from.Host, "*.") {
+		ru.host, ru.wildcard = from.Host[1:], true
+	}
+	if len(from.Host) < 1 && !strings.HasPrefix(from.Path, "/") {
+		return nil, errors.Errorf("cannot redirect from %v, want an absolute URL or a path starting with /", ru.from)
+	}
+	if len(from.Host) > 0 && !servesHost(ru, domains) {
+		return nil, errors.Errorf("cannot redirect from %v, not serving the domain", ru.from)
+	}
+
+	segments := strings.Split(from.Path, "/")
+	captures := map[string]bool{}
+	isPattern := ru.wildcard
+	for i, seg := range segments {
+		switch {
+		case seg == "*" && i == len(segments)-1:
+			ru.rest, isPattern = true, true
+			captures["*"], captures[splat] = true, true
+		case strings.Contains(seg, "*"):
+			return nil, errors.Errorf("cannot redirect from %v, * must be the last path segment", ru.from)
+		case strings.HasPrefix(seg, ":"):
+			isPattern = true
+			captures[seg] = true
+		default:
+			ru.literals++
 		}
+	}
+	if !isPattern {
+		return ru, nil
+	}
 
-		k := redirectKey(host, path, r.URL.RawQuery)
-		rdURL, exist := index[k]
-		if !exist {
-			next.ServeHTTP(w, r)
-			return
+	if len(from.RawQuery) > 0 {
+		return nil, errors.Errorf("cannot redirect from %v, patterns cannot have a query", ru.from)
+	}
+	for _, seg := range strings.Split(rd.ToURL.Path, "/") {
+		if (seg == "*" || strings.HasPrefix(seg, ":")) && !captures[seg] {
+			return nil, errors.Errorf("cannot redirect to %v, %s is not captured in %v", ru.to, seg, ru.from)
 		}
+	}
+	ru.segments = segments
+
+	return ru, nil
+}
+
+// splat also names the "*" capture in ToURL, as in Netlify's _redirects.
+const splat = ":splat"
+
+// servesHost returns true if the host of ru is one of domains, or a wildcard matching one.
+func servesHost(ru *rule, domains []string) bool {
+	for _, d := range domains {
+		if ru.host == d || (ru.wildcard && strings.HasSuffix(d, ru.host)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.TryRedirect(w, r) {
+		h.next.ServeHTTP(w, r)
+	}
+}
+
+// TryRedirect redirects r and returns true if a Redirection matches it.
+func (h *Handler) TryRedirect(w http.ResponseWriter, r *http.Request) bool {
+	host := r.URL.Host
+	if len(r.URL.Host) < 1 {
+		host = r.Host
+	}
+
+	ru, target, ok := h.set.Load().(*ruleSet).find(host, r.URL.Path, r.URL.RawQuery)
+	if !ok {
+		return false
+	}
+
+	h.redirect(w, r, ru, target)
+	return true
+}
+
+// find returns the rule matching a request, in order of precedence, and where it redirects to.
+func (set *ruleSet) find(host, path, rawQuery string) (*rule, url.URL, bool) {
+	for _, k := range []string{redirectKey(host, path, rawQuery), redirectKey("", path, rawQuery)} {
+		if ru, exist := set.exactQuery[k]; exist {
+			return ru, ru.target, true
+		}
+	}
+	for _, k := range []string{host + path, path} {
+		if ru, exist := set.exactPath[k]; exist {
+			target := ru.target
+			target.RawQuery = joinQuery(target.RawQuery, rawQuery)
+			return ru, target, true
+		}
+	}
+	for _, ru := range set.patterns {
+		captures, ok := ru.match(host, path)
+		if !ok {
+			continue
+		}
+
+		target := ru.target
+		target.Path = substitute(target.Path, captures)
+		target.RawPath = ""
+		target.RawQuery = joinQuery(target.RawQuery, rawQuery)
+		return ru, target, true
+	}
+
+	return nil, url.URL{}, false
+}
+
+func (h *Handler) redirect(w http.ResponseWriter, r *http.Request, ru *rule, target url.URL) {
+	atomic.AddUint64(&ru.hits, 1)
+	http.Redirect(w, r, target.String(), ru.status)
+}
+
+// match returns the captured path segments if host and path match the pattern.
+func (ru *rule) match(host, path string) (map[string]string, bool) {
+	switch {
+	case ru.wildcard && !strings.HasSuffix(host, ru.host):
+		return nil, false
+	case !ru.wildcard && len(ru.host) > 0 && host != ru.host:
+		return nil, false
+	}
+
+	segments := strings.Split(path, "/")
+	captures := map[string]string{}
+	for i, seg := range ru.segments {
+		if ru.rest && i == len(ru.segments)-1 {
+			if i < len(segments) {
+				captures["*"] = strings.Join(segments[i:], "/")
+			} else {
+				captures["*"] = ""
+			}
+			captures[splat] = captures["*"]
+			return captures, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			if len(segments[i]) < 1 {
+				return nil, false
+			}
+			captures[seg] = segments[i]
+		case seg != segments[i]:
+			return nil, false
+		}
+	}
+
+	return captures, len(segments) == len(ru.segments)
+}
+
+// substitute replaces the segments of path naming a capture by its value.
+func substitute(path string, captures map[string]string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if v, exist := captures[seg]; exist {
+			segments[i] = v
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func joinQuery(a, b string) string {
+	if len(a) < 1 || len(b) < 1 {
+		return a + b
+	}
+
+	return a + "&" + b
+}
 
-		http.Redirect(w, r, rdURL, http.StatusMovedPermanently)
+// Hits returns the number of requests redirected by each Redirection, in order.
+func (h *Handler) Hits() []Hit {
+	set := h.set.Load().(*ruleSet)
+	hits := make([]Hit, 0, len(set.rules))
+	for _, ru := range set.rules {
+		hits = append(hits, Hit{From: ru.from, To: ru.to, Count: atomic.LoadUint64(&ru.hits)})
 	}
 
-	return http.HandlerFunc(handler), nil
+	return hits
 }
 
 func redirectKey(host, path, rawQuery string) string {
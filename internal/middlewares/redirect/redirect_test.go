@@ -39,6 +39,12 @@ func TestRedirectionsString(t *testing.T) {
 			},
 			"{http://subdomain.example.com/ => https://example.com/path?with=query, host.only/ => another.host:80/, }",
 		},
+		{
+			Redirections{
+				Redirection{FromURL: *url1, ToURL: *url2, Status: 307},
+			},
+			"{http://subdomain.example.com/ => https://example.com/path?with=query => 307, }",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -73,12 +79,26 @@ func TestRedirectionsSet(t *testing.T) {
 			},
 			nil,
 		},
+		{
+			"with status",
+			"host.only/=>https://example.com/path?with=query=>302",
+			Redirections{
+				Redirection{FromURL: *url3, ToURL: *url2, Status: 302},
+			},
+			nil,
+		},
 		{
 			"no redirection",
 			"",
 			nil,
 			nil,
 		},
+		{
+			"bad status",
+			"host.only/=>https://example.com/path?with=query=>200",
+			nil,
+			errors.New("status of redirection \"host.only/=>https://example.com/path?with=query=>200\" must be 301, 302, 307 or 308"),
+		},
 		{
 			"bad redirection",
 			"host.only== https://example.com/path?with=query",
@@ -149,6 +169,54 @@ func TestNewHandler(t *testing.T) {
 			[]string{},
 			errors.New("cannot redirect from http://subdomain.example.com/, not serving the domain"),
 		},
+		{
+			"bad status",
+			Redirections{
+				Redirection{FromURL: *url1, ToURL: *url5, Status: 200},
+			},
+			[]string{"subdomain.example.com"},
+			errors.New("cannot redirect from http://subdomain.example.com/ with status 200, want 301, 302, 307 or 308"),
+		},
+		{
+			"wildcard not serving domain",
+			Redirections{
+				Redirection{FromURL: mustParse("https://*.example.org/"), ToURL: *url5},
+			},
+			[]string{"example.com", "subdomain.example.com"},
+			errors.New("cannot redirect from https://*.example.org/, not serving the domain"),
+		},
+		{
+			"star not last",
+			Redirections{
+				Redirection{FromURL: mustParse("https://example.com/*/old"), ToURL: *url5},
+			},
+			[]string{"example.com"},
+			errors.New("cannot redirect from https://example.com/*/old, * must be the last path segment"),
+		},
+		{
+			"pattern with query",
+			Redirections{
+				Redirection{FromURL: mustParse("https://example.com/:id?q=v"), ToURL: *url5},
+			},
+			[]string{"example.com"},
+			errors.New("cannot redirect from https://example.com/:id?q=v, patterns cannot have a query"),
+		},
+		{
+			"capture not in pattern",
+			Redirections{
+				Redirection{FromURL: mustParse("https://example.com/old/*"), ToURL: mustParse("https://example.com/:year/*")},
+			},
+			[]string{"example.com"},
+			errors.New("cannot redirect to https://example.com/:year/*, :year is not captured in https://example.com/old/*"),
+		},
+		{
+			"relative path",
+			Redirections{
+				Redirection{FromURL: mustParse("old-blog/*"), ToURL: *url5},
+			},
+			[]string{"example.com"},
+			errors.New("cannot redirect from old-blog/*, want an absolute URL or a path starting with /"),
+		},
 		{
 			"not HTTP or HTTPS",
 			Redirections{
@@ -178,6 +246,81 @@ func TestNewHandler(t *testing.T) {
 	}
 }
 
+func mustParse(s string) url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return *u
+}
+
+func TestHandlerPatterns(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handled-By", "next")
+	})
+	rds := Redirections{
+		{FromURL: mustParse("https://*.example.com/*"), ToURL: mustParse("https://example.com/*")},
+		{FromURL: mustParse("https://example.com/old-blog/*"), ToURL: mustParse("/2018/*"), Status: http.StatusFound},
+		{FromURL: mustParse("https://example.com/old-blog/about"), ToURL: mustParse("/about"), Status: http.StatusPermanentRedirect},
+		{FromURL: mustParse("https://example.com/posts/:year/:slug"), ToURL: mustParse("/:year/:slug?from=posts"), Status: http.StatusTemporaryRedirect},
+		{FromURL: mustParse("https://example.com/posts/2018/:slug"), ToURL: mustParse("/2018/07/:slug")},
+		{FromURL: mustParse("https://example.com/search?q=go"), ToURL: mustParse("/tags/go")},
+		{FromURL: mustParse("https://example.com/search"), ToURL: mustParse("/")},
+	}
+	handler, err := NewHandler(next, rds, "example.com", "www.example.com")
+	if err != nil {
+		t.Fatalf("cannot create handler; err = %v", err)
+	}
+
+	var testCases = []struct {
+		name             string
+		host             string
+		pathAndQuery     string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"prefix", "example.com", "/old-blog/07/21/post", http.StatusFound, "/2018/07/21/post"},
+		{"prefix with query", "example.com", "/old-blog/post?ref=rss", http.StatusFound, "/2018/post?ref=rss"},
+		{"empty rest", "example.com", "/old-blog/", http.StatusFound, "/2018/"},
+		{"exact before prefix", "example.com", "/old-blog/about", http.StatusPermanentRedirect, "/about"},
+		{"exact ignores query", "example.com", "/old-blog/about?x=1", http.StatusPermanentRedirect, "/about?x=1"},
+		{"captures", "example.com", "/posts/2017/hello", http.StatusTemporaryRedirect, "/2017/hello?from=posts"},
+		{"captures with query", "example.com", "/posts/2017/hello?a=b", http.StatusTemporaryRedirect, "/2017/hello?from=posts&a=b"},
+		{"most literal first", "example.com", "/posts/2018/hello", http.StatusMovedPermanently, "/2018/07/hello"},
+		{"exact query first", "example.com", "/search?q=go", http.StatusMovedPermanently, "/tags/go"},
+		{"then exact path", "example.com", "/search?q=rust", http.StatusMovedPermanently, "/?q=rust"},
+		{"wildcard host", "www.example.com", "/2018/07/21/post", http.StatusMovedPermanently, "https://example.com/2018/07/21/post"},
+		{"wildcard excludes apex", "example.com", "/2018/07/21/post", 0, ""},
+		{"too few segments", "example.com", "/posts/2017", 0, ""},
+		{"too many segments", "example.com", "/posts/2017/hello/world", 0, ""},
+		{"empty capture", "example.com", "/posts//hello", 0, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.pathAndQuery, nil)
+			r.Host = tc.host
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			resp := w.Result()
+
+			if len(tc.expectedLocation) < 1 {
+				if resp.Header.Get("X-Handled-By") != "next" {
+					t.Errorf("non-redirect request not handled by next")
+				}
+				return
+			}
+			if want, got := tc.expectedStatus, resp.StatusCode; want != got {
+				t.Errorf("wrong status code, want= %v, got= %v", want, got)
+			}
+			if want, got := tc.expectedLocation, resp.Header.Get("Location"); want != got {
+				t.Errorf("wrong Location header\n  want= %v\n   got= %v", want, got)
+			}
+		})
+	}
+}
+
 func TestHandlerRedirect(t *testing.T) {
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Handled-By", "next")
@@ -243,3 +386,71 @@ func TestHandlerRedirect(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlerHits(t *testing.T) {
+	rds := Redirections{
+		Redirection{FromURL: *url1, ToURL: *url5},
+		Redirection{FromURL: *url2, ToURL: *url5},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler, err := NewHandler(next, rds, "example.com", "subdomain.example.com")
+	if err != nil {
+		t.Fatalf("cannot create handler; err = %v", err)
+	}
+
+	for _, host := range []string{"subdomain.example.com", "subdomain.example.com", "not.redirectable"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = host
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	expected := []Hit{
+		{From: url1.String(), To: url5.String(), Count: 2},
+		{From: url2.String(), To: url5.String(), Count: 0},
+	}
+	if want, got := expected, handler.Hits(); !cmp.Equal(want, got) {
+		t.Errorf("wrong hits\n  want= %v\n   got= %v", want, got)
+	}
+}
+
+func TestHandlerReload(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	oldBlog := Redirection{FromURL: mustParse("/old-blog/*"), ToURL: mustParse("/2018/*")}
+	handler, err := NewHandler(next, Redirections{oldBlog}, "example.com")
+	if err != nil {
+		t.Fatalf("cannot create handler; err = %v", err)
+	}
+
+	get := func(path string) string {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Host = "example.com"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result().Header.Get("Location")
+	}
+	get("/old-blog/post")
+
+	feed := Redirection{FromURL: mustParse("/feed"), ToURL: mustParse("/feed.atom")}
+	if err := handler.Reload(Redirections{oldBlog, feed}); err != nil {
+		t.Fatalf("want Reload() to return no error, got= %v", err)
+	}
+	if want, got := "/feed.atom", get("/feed"); want != got {
+		t.Errorf("wrong Location after reload, want= %q, got= %q", want, got)
+	}
+
+	bad := Redirection{FromURL: mustParse("https://example.org/"), ToURL: mustParse("/")}
+	if err := handler.Reload(Redirections{bad}); err == nil {
+		t.Errorf("want Reload() to return an error, got none")
+	}
+	if want, got := "/2018/post", get("/old-blog/post"); want != got {
+		t.Errorf("wrong Location after a bad reload, want= %q, got= %q", want, got)
+	}
+
+	expected := []Hit{
+		{From: "/old-blog/*", To: "/2018/*", Count: 2},
+		{From: "/feed", To: "/feed.atom", Count: 1},
+	}
+	if want, got := expected, handler.Hits(); !cmp.Equal(want, got) {
+		t.Errorf("wrong hits\n  want= %v\n   got= %v", want, got)
+	}
+}
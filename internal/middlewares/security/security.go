@@ -0,0 +1,146 @@
+package security
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Policy is the set of security headers sent with every response.
+// Empty fields send no header.
+type Policy struct {
+	// Strict-Transport-Security max-age, only sent over HTTPS; 0 disables it
+	HSTSMaxAge time.Duration
+
+	ContentSecurityPolicy string // e.g default-src 'self'
+	FrameAncestors        string // added to the Content-Security-Policy, e.g 'none'
+	ReferrerPolicy        string // e.g strict-origin-when-cross-origin
+	PermissionsPolicy     string // e.g camera=(), microphone=()
+}
+
+// Remove, as the value of an Override field, removes the header instead of
+// keeping the one of the base policy.
+const Remove = "-"
+
+// Override changes the policy for the paths matching Path, a path.Match pattern
+// such as /resume or /projects/*, without the .html extension.
+// Empty fields keep the value of the base policy.
+type Override struct {
+	Path string
+	Policy
+}
+
+// DefaultPolicy only allows content from the site itself, except inline styles
+// and images from data: URLs, which posts use.
+var DefaultPolicy = Policy{
+	HSTSMaxAge:            365 * 24 * time.Hour,
+	ContentSecurityPolicy: "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; object-src 'none'; base-uri 'self'",
+	FrameAncestors:        "'none'",
+	ReferrerPolicy:        "strict-origin-when-cross-origin",
+	PermissionsPolicy:     "camera=(), microphone=(), geolocation=(), interest-cohort=()",
+}
+
+// NewHandler sets the headers of p on the responses of next, or those of the
+// first matching override. X-Content-Type-Options: nosniff is always sent.
+func NewHandler(next http.Handler, p Policy, overrides ...Override) (http.Handler, error) {
+	base := p.headers()
+	type rule struct {
+		pattern string
+		headers http.Header
+	}
+	rules := make([]rule, 0, len(overrides))
+	for _, o := range overrides {
+		if _, err := path.Match(o.Path, "/"); err != nil || !strings.HasPrefix(o.Path, "/") {
+			return nil, errors.Errorf("invalid path pattern %q in security policy override", o.Path)
+		}
+		rules = append(rules, rule{pattern: o.Path, headers: p.Merge(o.Policy).headers()})
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		headers := base
+		name := strings.TrimSuffix(r.URL.Path, ".html")
+		for _, rule := range rules {
+			if matched, _ := path.Match(rule.pattern, name); matched {
+				headers = rule.headers
+				break
+			}
+		}
+
+		for k, v := range headers {
+			if k == "Strict-Transport-Security" && r.TLS == nil {
+				continue // ignored by browsers over HTTP anyway
+			}
+			w.Header()[k] = append([]string(nil), v...)
+		}
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(handler), nil
+}
+
+// Merge returns p with the non-empty fields of o; fields set to Remove are emptied.
+func (p Policy) Merge(o Policy) Policy {
+	pick := func(base, override string) string {
+		switch override {
+		case "":
+			return base
+		case Remove:
+			return ""
+		default:
+			return override
+		}
+	}
+
+	res := Policy{
+		HSTSMaxAge:            p.HSTSMaxAge,
+		ContentSecurityPolicy: pick(p.ContentSecurityPolicy, o.ContentSecurityPolicy),
+		FrameAncestors:        pick(p.FrameAncestors, o.FrameAncestors),
+		ReferrerPolicy:        pick(p.ReferrerPolicy, o.ReferrerPolicy),
+		PermissionsPolicy:     pick(p.PermissionsPolicy, o.PermissionsPolicy),
+	}
+	if o.HSTSMaxAge != 0 {
+		res.HSTSMaxAge = o.HSTSMaxAge
+	}
+
+	return res
+}
+
+func (p Policy) headers() http.Header {
+	h := make(http.Header)
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	if p.HSTSMaxAge > 0 {
+		h.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d", int(p.HSTSMaxAge.Seconds())))
+	}
+
+	var csp []string
+	if len(p.ContentSecurityPolicy) > 0 {
+		csp = append(csp, strings.TrimRight(strings.TrimSpace(p.ContentSecurityPolicy), ";"))
+	}
+	if len(p.FrameAncestors) > 0 {
+		csp = append(csp, "frame-ancestors "+p.FrameAncestors)
+		// for browsers without frame-ancestors support
+		switch p.FrameAncestors {
+		case "'none'":
+			h.Set("X-Frame-Options", "DENY")
+		case "'self'":
+			h.Set("X-Frame-Options", "SAMEORIGIN")
+		}
+	}
+	if len(csp) > 0 {
+		h.Set("Content-Security-Policy", strings.Join(csp, "; "))
+	}
+
+	if len(p.ReferrerPolicy) > 0 {
+		h.Set("Referrer-Policy", p.ReferrerPolicy)
+	}
+	if len(p.PermissionsPolicy) > 0 {
+		h.Set("Permissions-Policy", p.PermissionsPolicy)
+	}
+
+	return h
+}
@@ -0,0 +1,139 @@
+package security
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func TestNewHandler(t *testing.T) {
+	p := Policy{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		ContentSecurityPolicy: "default-src 'self';",
+		FrameAncestors:        "'none'",
+		ReferrerPolicy:        "no-referrer",
+		PermissionsPolicy:     "camera=()",
+	}
+	overrides := []Override{
+		{Path: "/resume", Policy: Policy{ContentSecurityPolicy: "default-src 'self' https://fonts.example.com", FrameAncestors: "'self'"}},
+		{Path: "/projects/*", Policy: Policy{PermissionsPolicy: Remove}},
+		{Path: "/drafts/*", Policy: Policy{HSTSMaxAge: -time.Second}},
+	}
+	handler, err := NewHandler(okHandler, p, overrides...)
+	if err != nil {
+		t.Fatalf("want NewHandler() to return no error, got= %v", err)
+	}
+
+	var testCases = []struct {
+		name     string
+		url      string
+		https    bool
+		expected map[string]string
+	}{
+		{
+			name:  "base policy over https",
+			url:   "https://example.com/2018/07/21/post",
+			https: true,
+			expected: map[string]string{
+				"Strict-Transport-Security": "max-age=31536000",
+				"Content-Security-Policy":   "default-src 'self'; frame-ancestors 'none'",
+				"X-Frame-Options":           "DENY",
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "no-referrer",
+				"Permissions-Policy":        "camera=()",
+			},
+		},
+		{
+			name: "no HSTS over http",
+			url:  "http://example.com/",
+			expected: map[string]string{
+				"Strict-Transport-Security": "",
+				"X-Content-Type-Options":    "nosniff",
+			},
+		},
+		{
+			name:  "override",
+			url:   "https://example.com/resume",
+			https: true,
+			expected: map[string]string{
+				"Strict-Transport-Security": "max-age=31536000",
+				"Content-Security-Policy":   "default-src 'self' https://fonts.example.com; frame-ancestors 'self'",
+				"X-Frame-Options":           "SAMEORIGIN",
+				"Referrer-Policy":           "no-referrer",
+				"Permissions-Policy":        "camera=()",
+			},
+		},
+		{
+			name: "override with .html",
+			url:  "http://example.com/resume.html",
+			expected: map[string]string{
+				"Content-Security-Policy": "default-src 'self' https://fonts.example.com; frame-ancestors 'self'",
+			},
+		},
+		{
+			name: "removed header",
+			url:  "http://example.com/projects/notebook",
+			expected: map[string]string{
+				"Content-Security-Policy": "default-src 'self'; frame-ancestors 'none'",
+				"Permissions-Policy":      "",
+			},
+		},
+		{
+			name:  "HSTS turned off",
+			url:   "https://example.com/drafts/post",
+			https: true,
+			expected: map[string]string{
+				"Strict-Transport-Security": "",
+				"Referrer-Policy":           "no-referrer",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.https {
+				req.TLS = &tls.ConnectionState{}
+			} else {
+				req.TLS = nil
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+			resp := w.Result()
+			for header, expected := range tc.expected {
+				if want, got := expected, resp.Header.Get(header); want != got {
+					t.Errorf("wrong %s header, want= %q, got= %q", header, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewHandlerInvalidOverride(t *testing.T) {
+	for _, pattern := range []string{"resume", "/[projects"} {
+		if _, err := NewHandler(okHandler, DefaultPolicy, Override{Path: pattern}); err == nil {
+			t.Errorf("want error for pattern %q, got none", pattern)
+		}
+	}
+}
+
+func TestNewHandlerEmptyPolicy(t *testing.T) {
+	handler, err := NewHandler(okHandler, Policy{})
+	if err != nil {
+		t.Fatalf("want NewHandler() to return no error, got= %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/", nil))
+	resp := w.Result()
+	if want, got := 1, len(resp.Header)-1; want != got { // besides Content-Type
+		t.Errorf("want only X-Content-Type-Options, got= %v", resp.Header)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"html/template"
 	"io/ioutil"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/exklamationmark/glog"
@@ -18,19 +20,27 @@ import (
 type Metadata struct {
 	Author      string
 	PublishedAt time.Time
+	UpdatedAt   time.Time // zero if the post was never updated
 	Title       string
 	Slug        string
 	Sticky      bool
+	Draft       bool
+	Layout      string // empty for the default layout
 	Tags        []string
+	Aliases     []string // former paths of the post, e.g 2018/07/20/old-slug
 }
 
 type metdataYAML struct {
 	Author      string   `yaml:"author"`
 	PublishedAt string   `yaml:"published"`
+	UpdatedAt   string   `yaml:"updated"`
 	Title       string   `yaml:"title"`
 	Slug        string   `yaml:"slug"`
 	Sticky      bool     `yaml:"sticky"`
+	Draft       bool     `yaml:"draft"`
+	Layout      string   `yaml:"layout"`
 	Tags        []string `yaml:"tags"`
+	Aliases     []string `yaml:"aliases"`
 }
 
 type Post struct {
@@ -78,6 +88,16 @@ func (p *Post) CanonicalPath() string {
 	return fmt.Sprintf("%04d/%02d/%02d/%s", yyyy, mm, dd, p.Metadata.Slug)
 }
 
+// LastModified returns when the post was last changed, i.e UpdatedAt if set
+// and PublishedAt otherwise.
+func (p *Post) LastModified() time.Time {
+	if p.Metadata.UpdatedAt.After(p.Metadata.PublishedAt) {
+		return p.Metadata.UpdatedAt
+	}
+
+	return p.Metadata.PublishedAt
+}
+
 func (p *Post) ParentPath() string {
 	yyyy, mm, dd := p.Metadata.PublishedAt.Date()
 	return fmt.Sprintf("%04d/%02d/%02d", yyyy, mm, dd)
@@ -116,14 +136,55 @@ func decodeMetadata(raw []byte) (Metadata, error) {
 		return Metadata{}, errors.Wrapf(err, "published time=%s is not in RFC3339 format", base.PublishedAt)
 	}
 
+	var updatedAt time.Time
+	if len(base.UpdatedAt) > 0 {
+		updatedAt, err = time.Parse(time.RFC3339, base.UpdatedAt)
+		if err != nil {
+			return Metadata{}, errors.Wrapf(err, "updated time=%s is not in RFC3339 format", base.UpdatedAt)
+		}
+		updatedAt = updatedAt.UTC()
+	}
+
 	sort.Strings(base.Tags)
 
+	aliases := make([]string, 0, len(base.Aliases))
+	for _, a := range base.Aliases {
+		alias, err := cleanAlias(a)
+		if err != nil {
+			return Metadata{}, err
+		}
+		aliases = append(aliases, alias)
+	}
+	if len(aliases) < 1 {
+		aliases = nil
+	}
+
 	return Metadata{
 		Author:      base.Author,
 		Title:       base.Title,
 		Slug:        base.Slug,
 		PublishedAt: publishedAt.UTC(),
+		UpdatedAt:   updatedAt,
 		Sticky:      base.Sticky,
+		Draft:       base.Draft,
+		Layout:      base.Layout,
 		Tags:        base.Tags, // moved
+		Aliases:     aliases,
 	}, nil
 }
+
+// cleanAlias returns an alias in the form of CanonicalPath: without leading or
+// trailing slash and .html, e.g /2018/07/20/old-slug.html becomes 2018/07/20/old-slug.
+func cleanAlias(alias string) (string, error) {
+	if strings.ContainsAny(alias, ":*?#") {
+		return "", errors.Errorf("alias %q must be a path, e.g /2018/07/20/old-slug", alias)
+	}
+
+	cleaned := strings.TrimPrefix(path.Clean("/"+alias), "/")
+	cleaned = strings.TrimSuffix(cleaned, ".html")
+	if len(cleaned) < 1 || cleaned == "index" {
+		return "", errors.Errorf("alias %q must be the path of a page, not the index", alias)
+	}
+
+	return cleaned, nil
+}
@@ -56,6 +56,75 @@ func TestNew(t *testing.T) {
 				HTML: template.HTML("<h1>h1</h1>\n\n<p>pppppp\npppp</p>\n\n<ul>\n<li>li</li>\n<li>li</li>\n</ul>\n\n<blockquote>\n<p>bq</p>\n</blockquote>\n\n<pre><code>pre\ncode\n</code></pre>\n"),
 			},
 		},
+		{
+			name:        "updated",
+			filename:    "testdata/updated.md",
+			expectedErr: nil,
+			expected: &Post{
+				Filename: caller + "/testdata/updated.md",
+				Metadata: Metadata{
+					Title:       "untitled",
+					Slug:        "untitled",
+					Author:      "mark",
+					PublishedAt: mustParseRFC3339ToUTC("2018-07-21T08:00:00+08:00"),
+					UpdatedAt:   mustParseRFC3339ToUTC("2018-08-01T10:00:00+08:00"),
+					Sticky:      true,
+					Tags:        []string{"group1", "tags2", "test"}, // sorted
+				},
+				HTML: template.HTML("<h1>h1</h1>\n\n<p>pppppp\npppp</p>\n\n<ul>\n<li>li</li>\n<li>li</li>\n</ul>\n\n<blockquote>\n<p>bq</p>\n</blockquote>\n\n<pre><code>pre\ncode\n</code></pre>\n"),
+			},
+		},
+		{
+			name:        "draft",
+			filename:    "testdata/draft.md",
+			expectedErr: nil,
+			expected: &Post{
+				Filename: caller + "/testdata/draft.md",
+				Metadata: Metadata{
+					Title:       "untitled",
+					Slug:        "untitled",
+					Author:      "mark",
+					PublishedAt: mustParseRFC3339ToUTC("2018-07-21T08:00:00+08:00"),
+					Draft:       true,
+					Tags:        []string{"group1", "tags2", "test"}, // sorted
+				},
+				HTML: template.HTML("<h1>h1</h1>\n\n<p>pppppp\npppp</p>\n\n<ul>\n<li>li</li>\n<li>li</li>\n</ul>\n\n<blockquote>\n<p>bq</p>\n</blockquote>\n\n<pre><code>pre\ncode\n</code></pre>\n"),
+			},
+		},
+		{
+			name:        "layout",
+			filename:    "testdata/layout.md",
+			expectedErr: nil,
+			expected: &Post{
+				Filename: caller + "/testdata/layout.md",
+				Metadata: Metadata{
+					Title:       "untitled",
+					Slug:        "untitled",
+					Author:      "mark",
+					PublishedAt: mustParseRFC3339ToUTC("2018-07-21T08:00:00+08:00"),
+					Layout:      "page",
+					Tags:        []string{"group1", "tags2", "test"}, // sorted
+				},
+				HTML: template.HTML("<h1>h1</h1>\n\n<p>pppppp\npppp</p>\n\n<ul>\n<li>li</li>\n<li>li</li>\n</ul>\n\n<blockquote>\n<p>bq</p>\n</blockquote>\n\n<pre><code>pre\ncode\n</code></pre>\n"),
+			},
+		},
+		{
+			name:        "aliases",
+			filename:    "testdata/aliases.md",
+			expectedErr: nil,
+			expected: &Post{
+				Filename: caller + "/testdata/aliases.md",
+				Metadata: Metadata{
+					Title:       "untitled",
+					Slug:        "untitled",
+					Author:      "mark",
+					PublishedAt: mustParseRFC3339ToUTC("2018-07-21T08:00:00+08:00"),
+					Tags:        []string{"group1", "tags2", "test"}, // sorted
+					Aliases:     []string{"2018/07/20/old-slug", "2018/07/20/older-slug"},
+				},
+				HTML: template.HTML("<h1>h1</h1>\n\n<p>pppppp\npppp</p>\n\n<ul>\n<li>li</li>\n<li>li</li>\n</ul>\n\n<blockquote>\n<p>bq</p>\n</blockquote>\n\n<pre><code>pre\ncode\n</code></pre>\n"),
+			},
+		},
 		{
 			name:        "no metadata",
 			filename:    "testdata/no_metadata.md",
@@ -80,6 +149,18 @@ func TestNew(t *testing.T) {
 			expectedErr: errors.Errorf(`parsing time "2018-07-21" as "2006-01-02T15:04:05Z07:00": cannot parse "" as "T"`),
 			expected:    nil,
 		},
+		{
+			name:        "invalid updated at",
+			filename:    "testdata/invalid_updated_at.md",
+			expectedErr: errors.Errorf(`parsing time "2018-08-01" as "2006-01-02T15:04:05Z07:00": cannot parse "" as "T"`),
+			expected:    nil,
+		},
+		{
+			name:        "invalid alias",
+			filename:    "testdata/invalid_alias.md",
+			expectedErr: errors.Errorf(`alias "https://example.com/old" must be a path, e.g /2018/07/20/old-slug`),
+			expected:    nil,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -123,6 +204,27 @@ func TestCanonicalPath(t *testing.T) {
 	}
 }
 
+func TestLastModified(t *testing.T) {
+	var testCases = []struct {
+		filename string
+		expected time.Time
+	}{
+		{"testdata/happy.md", mustParseRFC3339ToUTC("2018-07-21T08:00:00+08:00")},
+		{"testdata/updated.md", mustParseRFC3339ToUTC("2018-08-01T10:00:00+08:00")},
+	}
+
+	for _, tc := range testCases {
+		p, err := New(tc.filename)
+		if err != nil {
+			t.Fatalf("cannot create Post, err= %v", err)
+		}
+
+		if want, got := tc.expected, p.LastModified(); !want.Equal(got) {
+			t.Errorf("mismatched LastModified() for %s, want= %v, got= %v", tc.filename, want, got)
+		}
+	}
+}
+
 // func TestRender(t *testing.T) {
 // 	tmpl := loadTemplate()
 // 	var buf bytes.Buffer
@@ -0,0 +1,104 @@
+package preview
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/exklamationmark/glog"
+)
+
+// Step is a part of the site build, re-run whenever one of its Paths changes.
+type Step struct {
+	Name  string
+	Paths []string // files or directories
+	Run   func() error
+}
+
+func (s Step) triggeredBy(changed []string) bool {
+	for _, fname := range changed {
+		for _, path := range s.Paths {
+			if isUnder(fname, path) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isUnder(fname, path string) bool {
+	fname, path = filepath.Clean(fname), filepath.Clean(path)
+	return fname == path || strings.HasPrefix(fname, path+string(filepath.Separator))
+}
+
+type Server struct {
+	steps   []Step
+	broker  *broker
+	handler http.Handler
+}
+
+// New wraps blogHandler so served pages reload themselves after any of steps re-runs.
+func New(blogHandler http.Handler, steps ...Step) *Server {
+	b := newBroker()
+
+	mux := http.NewServeMux()
+	mux.Handle(eventsPath, b)
+	mux.Handle("/", injectReload(blogHandler))
+
+	return &Server{
+		steps:   steps,
+		broker:  b,
+		handler: mux,
+	}
+}
+
+func (srv *Server) Handler() http.Handler {
+	return srv.handler
+}
+
+// Build runs every step once.
+func (srv *Server) Build() error {
+	for _, step := range srv.steps {
+		if err := step.Run(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Watch polls the paths of every step, re-runs the steps affected by a change
+// and tells the browsers to reload. It returns when stop is closed.
+func (srv *Server) Watch(interval time.Duration, stop <-chan struct{}) {
+	var roots []string
+	for _, step := range srv.steps {
+		roots = append(roots, step.Paths...)
+	}
+
+	watch(roots, interval, stop, srv.rebuild)
+}
+
+func (srv *Server) rebuild(changed []string) {
+	glog.V(0).Infof("changed: %v", changed)
+
+	rebuilt := false
+	for _, step := range srv.steps {
+		if !step.triggeredBy(changed) {
+			continue
+		}
+
+		start := time.Now()
+		if err := step.Run(); err != nil {
+			glog.Errorf("%s failed, keeping previous output, err= %v", step.Name, err)
+			continue
+		}
+		glog.V(0).Infof("rebuilt %s in %v", step.Name, time.Since(start))
+		rebuilt = true
+	}
+
+	if rebuilt {
+		srv.broker.reload()
+	}
+}
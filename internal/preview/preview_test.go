@@ -0,0 +1,47 @@
+package preview
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRebuild(t *testing.T) {
+	var ran []string
+	step := func(name string, paths ...string) Step {
+		return Step{
+			Name:  name,
+			Paths: paths,
+			Run: func() error {
+				ran = append(ran, name)
+				return nil
+			},
+		}
+	}
+
+	nop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	srv := New(nop, step("posts", "posts", "template.html"), step("assets", "assets"))
+
+	var testCases = []struct {
+		name     string
+		changed  []string
+		expected []string
+	}{
+		{"post", []string{"posts/a.md"}, []string{"posts"}},
+		{"template", []string{"template.html"}, []string{"posts"}},
+		{"asset", []string{"assets/prism.css"}, []string{"assets"}},
+		{"both", []string{"assets/prism.css", "posts/a.md"}, []string{"posts", "assets"}},
+		{"prefix only", []string{"assets-old/prism.css"}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ran = nil
+			srv.rebuild(tc.changed)
+			if want, got := tc.expected, ran; !cmp.Equal(want, got) {
+				t.Errorf("wrong steps re-run, want= %v, got= %v", want, got)
+			}
+		})
+	}
+}
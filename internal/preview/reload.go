@@ -0,0 +1,153 @@
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const eventsPath = "/_preview/events"
+
+var reloadScript = []byte(`<script type="text/javascript">
+(function() {
+	var events = new EventSource("` + eventsPath + `");
+	events.addEventListener("reload", function() { window.location.reload(); });
+})();
+</script>
+`)
+
+// broker fans reload notifications out to every connected browser tab,
+// using server-sent events.
+type broker struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{clients: make(map[chan struct{}]struct{})}
+}
+
+func (b *broker) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.clients[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch
+}
+
+func (b *broker) unsubscribe(ch chan struct{}) {
+	b.mu.Lock()
+	delete(b.clients, ch)
+	b.mu.Unlock()
+}
+
+func (b *broker) reload() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.clients {
+		select {
+		case ch <- struct{}{}:
+		default: // a reload is already pending for this client
+		}
+	}
+}
+
+func (b *broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// bufferedWriter holds on to a response so it can be rewritten before being sent.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// injectReload adds reloadScript to every HTML page served by next.
+func injectReload(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// always serve the full page, a 304 would leave nothing to inject into
+		r.Header.Del("If-Modified-Since")
+		r.Header.Del("If-None-Match")
+		// and uncompressed, to have something to inject into
+		r.Header.Del("Accept-Encoding")
+
+		buf := &bufferedWriter{header: w.Header()}
+		next.ServeHTTP(buf, r)
+		if buf.status == 0 {
+			buf.status = http.StatusOK
+		}
+
+		body := buf.body.Bytes()
+		if isHTML(buf.header.Get("Content-Type")) {
+			body = insertBeforeBodyEnd(body, reloadScript)
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Header().Del("ETag") // describes the file, not the page with the script
+		}
+		w.Header().Set("Cache-Control", "no-store")
+
+		w.WriteHeader(buf.status)
+		w.Write(body)
+	})
+}
+
+func isHTML(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/html")
+}
+
+func insertBeforeBodyEnd(page, snippet []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i < 0 {
+		return append(page, snippet...)
+	}
+
+	res := make([]byte, 0, len(page)+len(snippet))
+	res = append(res, page[:i]...)
+	res = append(res, snippet...)
+	res = append(res, page[i:]...)
+	return res
+}
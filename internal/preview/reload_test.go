@@ -0,0 +1,107 @@
+package preview
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInsertBeforeBodyEnd(t *testing.T) {
+	var testCases = []struct {
+		page     string
+		expected string
+	}{
+		{"<html><body><p>x</p></body></html>", "<html><body><p>x</p><s/></body></html>"},
+		{"<HTML><BODY></BODY></HTML>", "<HTML><BODY><s/></BODY></HTML>"},
+		{"<p>no body</p>", "<p>no body</p><s/>"},
+	}
+
+	for _, tc := range testCases {
+		if want, got := tc.expected, string(insertBeforeBodyEnd([]byte(tc.page), []byte("<s/>"))); want != got {
+			t.Errorf("wrong page\n  want= %q\n   got= %q", want, got)
+		}
+	}
+}
+
+func TestInjectReload(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		contentType string
+		body        string
+		expectedSub string
+		injected    bool
+	}{
+		{"html", "text/html; charset=utf-8", "<html><body></body></html>", "</script>\n</body>", true},
+		{"css", "text/css; charset=utf-8", "body{}", "body{}", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var conditional string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conditional = r.Header.Get("If-Modified-Since")
+				w.Header().Set("Content-Type", tc.contentType)
+				w.Write([]byte(tc.body))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("If-Modified-Since", "Mon, 01 Jan 2018 00:00:00 GMT")
+			w := httptest.NewRecorder()
+			injectReload(next).ServeHTTP(w, req)
+
+			resp := w.Result()
+			body, _ := ioutil.ReadAll(resp.Body)
+			if len(conditional) > 0 {
+				t.Errorf("want conditional headers to be removed, got If-Modified-Since= %q", conditional)
+			}
+			if want, got := http.StatusOK, resp.StatusCode; want != got {
+				t.Errorf("wrote wrong HTTP status, want= %v, got= %v", want, got)
+			}
+			if !strings.Contains(string(body), tc.expectedSub) {
+				t.Errorf("want body to contain %q, got= %q", tc.expectedSub, body)
+			}
+			if want, got := tc.injected, strings.Contains(string(body), eventsPath); want != got {
+				t.Errorf("wrong injection, want= %v, got= %v", want, got)
+			}
+		})
+	}
+}
+
+func TestBrokerReload(t *testing.T) {
+	b := newBroker()
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("cannot connect to event stream, err= %v", err)
+	}
+	defer resp.Body.Close()
+	if want, got := "text/event-stream", resp.Header.Get("Content-Type"); want != got {
+		t.Errorf("wrong Content-Type, want= %v, got= %v", want, got)
+	}
+
+	// wait for the client to be subscribed before broadcasting
+	for i := 0; i < 100; i++ {
+		b.mu.Lock()
+		n := len(b.clients)
+		b.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	b.reload()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("cannot read event, err= %v", err)
+	}
+	if want, got := "event: reload\n", line; want != got {
+		t.Errorf("wrong event, want= %q, got= %q", want, got)
+	}
+}
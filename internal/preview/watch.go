@@ -0,0 +1,76 @@
+package preview
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/exklamationmark/glog"
+)
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot records the state of every file under roots. Roots can be files or directories.
+func snapshot(roots ...string) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, root := range roots {
+		filepath.Walk(root, func(fname string, info os.FileInfo, err error) error {
+			if err != nil {
+				glog.V(1).Infof("cannot stat %q, err= %v", fname, err)
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			states[fname] = fileState{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+	}
+
+	return states
+}
+
+// diff returns the files that were added, removed or modified between two snapshots.
+func diff(before, after map[string]fileState) []string {
+	var changed []string
+	for fname, state := range after {
+		if prev, exist := before[fname]; !exist || prev != state {
+			changed = append(changed, fname)
+		}
+	}
+	for fname := range before {
+		if _, exist := after[fname]; !exist {
+			changed = append(changed, fname)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed
+}
+
+// watch polls roots every interval and calls onChange with the changed files
+// until stop is closed. Polling keeps us free of platform-specific file events.
+func watch(roots []string, interval time.Duration, stop <-chan struct{}, onChange func([]string)) {
+	prev := snapshot(roots...)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		cur := snapshot(roots...)
+		if changed := diff(prev, cur); len(changed) > 0 {
+			onChange(changed)
+		}
+		prev = cur
+	}
+}
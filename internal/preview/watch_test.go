@@ -0,0 +1,61 @@
+package preview
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	t0 := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := map[string]fileState{
+		"same":     {modTime: t0, size: 1},
+		"modified": {modTime: t0, size: 1},
+		"resized":  {modTime: t0, size: 1},
+		"removed":  {modTime: t0, size: 1},
+	}
+	after := map[string]fileState{
+		"same":     {modTime: t0, size: 1},
+		"modified": {modTime: t0.Add(time.Second), size: 1},
+		"resized":  {modTime: t0, size: 2},
+		"added":    {modTime: t0, size: 1},
+	}
+
+	if want, got := []string{"added", "modified", "removed", "resized"}, diff(before, after); !cmp.Equal(want, got) {
+		t.Errorf("wrong changed files, want= %v, got= %v", want, got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "preview")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+
+	changes := make(chan []string, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go watch([]string{dir}, 10*time.Millisecond, stop, func(changed []string) {
+		changes <- changed
+	})
+
+	time.Sleep(50 * time.Millisecond) // let watch take its first snapshot
+	fname := filepath.Join(dir, "post.md")
+	if err := ioutil.WriteFile(fname, []byte("hello"), 0664); err != nil {
+		t.Fatalf("cannot write file, err= %v", err)
+	}
+
+	select {
+	case changed := <-changes:
+		if want, got := []string{fname}, changed; !cmp.Equal(want, got) {
+			t.Errorf("wrong changed files, want= %v, got= %v", want, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("want change to be detected, got none")
+	}
+}
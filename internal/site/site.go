@@ -0,0 +1,244 @@
+package site
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/exklamationmark/notebook/internal/middlewares/accesslog"
+	"github.com/exklamationmark/notebook/internal/middlewares/redirect"
+	"github.com/exklamationmark/notebook/internal/middlewares/security"
+)
+
+// Config is the site configuration, usually read from notebook.yaml.
+// Empty values mean "not configured"; the command line defaults apply.
+type Config struct {
+	Title  string `yaml:"title"`
+	URL    string `yaml:"url"`
+	Author string `yaml:"author"` // for posts without an author
+
+	Dirs   Dirs   `yaml:"dirs"`
+	Feed   Feed   `yaml:"feed"`
+	Assets Assets `yaml:"assets"`
+	Server Server `yaml:"server"`
+}
+
+type Dirs struct {
+	HTML      string `yaml:"html"`
+	Posts     string `yaml:"posts"`
+	Templates string `yaml:"templates"`
+	Assets    string `yaml:"assets"`
+	ACMECache string `yaml:"acme_cache"` // Let's Encrypt keys and certificates
+}
+
+type Feed struct {
+	Tags  bool `yaml:"tags"`  // also generate a feed per tag
+	Limit int  `yaml:"limit"` // max. number of entries, 0 for all
+}
+
+// Assets lists files of the asset directory, in the order they are concatenated.
+type Assets struct {
+	CSS  []string `yaml:"css"`  // into builtin.css, all *.css by default
+	JS   []string `yaml:"js"`   // into builtin.js, all *.js by default
+	Copy []string `yaml:"copy"` // as-is, e.g favicons
+}
+
+type Server struct {
+	HTTPAddr   string     `yaml:"http_addr"`   // host:port, unix:/path or systemd:name
+	HTTPSAddr  string     `yaml:"https_addr"`  // host:port, unix:/path or systemd:name
+	UnixSocket string     `yaml:"unix_socket"` // optional, e.g for a reverse proxy
+	AdminAddr  string     `yaml:"admin_addr"`  // optional, serves /metrics
+	AdminEmail string     `yaml:"admin_email"`
+	Domains    []string   `yaml:"domains"`
+	Redirects  []Redirect `yaml:"redirects"`
+
+	// host that requests for the other domains are redirected to, e.g example.com
+	CanonicalHost string `yaml:"canonical_host"`
+
+	// more redirects, reloaded on change or SIGHUP; see redirect.ParseFile
+	RedirectsFile string `yaml:"redirects_file"`
+
+	// Cache-Control header by file extension (e.g .html), "" for the others
+	CacheControl map[string]string `yaml:"cache_control"`
+
+	Security  Security  `yaml:"security"`
+	AccessLog AccessLog `yaml:"access_log"`
+}
+
+// AccessLog configures the access log, off unless File is set.
+type AccessLog struct {
+	File           string        `yaml:"file"`            // "-" for stdout
+	Format         string        `yaml:"format"`          // common, combined or json
+	TrustedProxies []string      `yaml:"trusted_proxies"` // IPs or CIDRs allowed to set X-Forwarded-For
+	MaxSizeMB      int           `yaml:"max_size_mb"`     // rotate when the file would grow bigger
+	RotateEvery    time.Duration `yaml:"rotate_every"`    // rotate when the file gets older
+	MaxBackups     int           `yaml:"max_backups"`     // rotated files to keep, 0 for all
+}
+
+// Security changes the default security headers; "-" removes one.
+type Security struct {
+	SecurityHeaders `yaml:",inline"`
+	Overrides       []SecurityOverride `yaml:"overrides"` // the first matching one applies
+}
+
+type SecurityOverride struct {
+	Path            string `yaml:"path"` // e.g /resume or /projects/*, without .html
+	SecurityHeaders `yaml:",inline"`
+}
+
+type SecurityHeaders struct {
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age"` // production only, negative to turn off
+	ContentSecurityPolicy string        `yaml:"content_security_policy"`
+	FrameAncestors        string        `yaml:"frame_ancestors"`
+	ReferrerPolicy        string        `yaml:"referrer_policy"`
+	PermissionsPolicy     string        `yaml:"permissions_policy"`
+}
+
+// Redirect from a URL or pattern (see redirect.Redirection) to another.
+type Redirect struct {
+	From   string `yaml:"from"`   // e.g https://example.com/old-blog/*
+	To     string `yaml:"to"`     // e.g /2018/*
+	Status int    `yaml:"status"` // 301 (default), 302, 307 or 308
+}
+
+// Load reads and validates a config file. Unknown keys are errors, to catch typos.
+func Load(fname string) (*Config, error) {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read config %q", fname)
+	}
+
+	var c Config
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse config %q", fname)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid config %q", fname)
+	}
+
+	return &c, nil
+}
+
+// ValidationErrors lists every problem found in a config.
+type ValidationErrors []string
+
+func (errs ValidationErrors) Error() string {
+	return strings.Join(errs, "; ")
+}
+
+func (c *Config) Validate() error {
+	var errs ValidationErrors
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(c.URL) > 0 {
+		u, err := url.Parse(c.URL)
+		if err != nil || !(u.Scheme == "http" || u.Scheme == "https") || len(u.Host) < 1 {
+			invalid("url", "must be an absolute http(s) URL, got %q", c.URL)
+		}
+	}
+	if c.Feed.Limit < 0 {
+		invalid("feed.limit", "must not be negative, got %d", c.Feed.Limit)
+	}
+	if len(c.Server.AdminEmail) > 0 && !strings.Contains(c.Server.AdminEmail, "@") {
+		invalid("server.admin_email", "must be an email address, got %q", c.Server.AdminEmail)
+	}
+	for i, domain := range c.Server.Domains {
+		if len(domain) < 1 || strings.ContainsAny(domain, "/:@ ") {
+			invalid(fmt.Sprintf("server.domains[%d]", i), "must be a host name, got %q", domain)
+		}
+	}
+	if strings.ContainsAny(c.Server.CanonicalHost, "/:@ ") {
+		invalid("server.canonical_host", "must be a host name, got %q", c.Server.CanonicalHost)
+	}
+	for i, rd := range c.Server.Redirects {
+		if _, err := rd.redirection(); err != nil {
+			invalid(fmt.Sprintf("server.redirects[%d]", i), "%v", err)
+		}
+	}
+	for i, o := range c.Server.Security.Overrides {
+		if _, err := path.Match(o.Path, "/"); err != nil || !strings.HasPrefix(o.Path, "/") {
+			invalid(fmt.Sprintf("server.security.overrides[%d].path", i), "must be a path pattern starting with /, got %q", o.Path)
+		}
+	}
+	for ext := range c.Server.CacheControl {
+		if len(ext) > 0 && !strings.HasPrefix(ext, ".") {
+			invalid("server.cache_control", "keys must be extensions starting with a dot, got %q", ext)
+		}
+	}
+
+	switch accesslog.Format(c.Server.AccessLog.Format) {
+	case "", accesslog.Common, accesslog.Combined, accesslog.JSON:
+	default:
+		invalid("server.access_log.format", "must be common, combined or json, got %q", c.Server.AccessLog.Format)
+	}
+	for i, proxy := range c.Server.AccessLog.TrustedProxies {
+		if !isIPOrCIDR(proxy) {
+			invalid(fmt.Sprintf("server.access_log.trusted_proxies[%d]", i), "must be an IP address or CIDR range, got %q", proxy)
+		}
+	}
+	if c.Server.AccessLog.MaxSizeMB < 0 || c.Server.AccessLog.RotateEvery < 0 || c.Server.AccessLog.MaxBackups < 0 {
+		invalid("server.access_log", "max_size_mb, rotate_every and max_backups must not be negative")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func isIPOrCIDR(s string) bool {
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		return true
+	}
+
+	return net.ParseIP(s) != nil
+}
+
+// SecurityPolicy returns the security headers: the defaults, changed by the config.
+func (c *Config) SecurityPolicy() (security.Policy, []security.Override) {
+	p := security.DefaultPolicy.Merge(c.Server.Security.policy())
+
+	overrides := make([]security.Override, 0, len(c.Server.Security.Overrides))
+	for _, o := range c.Server.Security.Overrides {
+		overrides = append(overrides, security.Override{Path: o.Path, Policy: o.policy()})
+	}
+
+	return p, overrides
+}
+
+func (h SecurityHeaders) policy() security.Policy {
+	return security.Policy{
+		HSTSMaxAge:            h.HSTSMaxAge,
+		ContentSecurityPolicy: h.ContentSecurityPolicy,
+		FrameAncestors:        h.FrameAncestors,
+		ReferrerPolicy:        h.ReferrerPolicy,
+		PermissionsPolicy:     h.PermissionsPolicy,
+	}
+}
+
+// Redirections converts the configured redirects for the redirect middleware.
+func (c *Config) Redirections() (redirect.Redirections, error) {
+	rds := make(redirect.Redirections, 0, len(c.Server.Redirects))
+	for _, rd := range c.Server.Redirects {
+		res, err := rd.redirection()
+		if err != nil {
+			return nil, err
+		}
+		rds = append(rds, res)
+	}
+
+	return rds, nil
+}
+
+func (rd Redirect) redirection() (redirect.Redirection, error) {
+	return redirect.ParseRedirection(rd.From, rd.To, rd.Status)
+}
@@ -0,0 +1,179 @@
+package site
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/exklamationmark/notebook/internal/middlewares/redirect"
+	"github.com/exklamationmark/notebook/internal/middlewares/security"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load("testdata/full.yaml")
+	if err != nil {
+		t.Fatalf("want Load() to return no error, got= %v", err)
+	}
+
+	expected := &Config{
+		Title:  "Bitsgofer",
+		URL:    "https://bitsgofer.com",
+		Author: "mark",
+		Dirs: Dirs{
+			HTML:      "public_html",
+			Posts:     "posts",
+			Templates: "templates",
+			Assets:    "assets",
+			ACMECache: "/var/lib/notebook/acme",
+		},
+		Feed: Feed{Tags: true, Limit: 20},
+		Assets: Assets{
+			CSS:  []string{"mini-default.css", "prism.css"},
+			JS:   []string{"prism.js"},
+			Copy: []string{"favicon.ico", "favicon-256.png"},
+		},
+		Server: Server{
+			HTTPAddr:   "systemd:http",
+			HTTPSAddr:  "systemd:https",
+			UnixSocket: "/run/notebook/notebook.sock",
+			AdminAddr:  "localhost:9100",
+			AdminEmail: "admin@bitsgofer.com",
+			Domains:    []string{"bitsgofer.com", "www.bitsgofer.com"},
+			Redirects: []Redirect{
+				{From: "http://old.bitsgofer.com/", To: "https://bitsgofer.com/"},
+				{From: "https://bitsgofer.com/old-blog/*", To: "/2018/*", Status: 302},
+			},
+			RedirectsFile: "/etc/notebook/_redirects",
+			CanonicalHost: "bitsgofer.com",
+			CacheControl: map[string]string{
+				".html": "no-cache",
+				"":      "public, max-age=600",
+			},
+			Security: Security{
+				SecurityHeaders: SecurityHeaders{
+					HSTSMaxAge:        720 * time.Hour,
+					ReferrerPolicy:    "no-referrer",
+					PermissionsPolicy: "-",
+				},
+				Overrides: []SecurityOverride{
+					{
+						Path:            "/resume",
+						SecurityHeaders: SecurityHeaders{ContentSecurityPolicy: "default-src 'self' https://fonts.example.com"},
+					},
+				},
+			},
+			AccessLog: AccessLog{
+				File:           "/var/log/notebook/access.log",
+				Format:         "json",
+				TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8"},
+				MaxSizeMB:      100,
+				RotateEvery:    24 * time.Hour,
+				MaxBackups:     7,
+			},
+		},
+	}
+	if want, got := expected, c; !cmp.Equal(want, got) {
+		t.Errorf("mismatched Config\n  want= %#v\n   got= %#v\n  diff= %v", want, got, cmp.Diff(want, got))
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		fname    string
+		expected []string
+	}{
+		{
+			"missing file",
+			"testdata/missing.yaml",
+			[]string{`cannot read config "testdata/missing.yaml"`},
+		},
+		{
+			"unknown key",
+			"testdata/unknown_key.yaml",
+			[]string{`cannot parse config`, `field domains not found`},
+		},
+		{
+			"invalid values",
+			"testdata/invalid.yaml",
+			[]string{
+				`url: must be an absolute http(s) URL, got "bitsgofer.com"`,
+				`feed.limit: must not be negative, got -1`,
+				`server.admin_email: must be an email address, got "nobody"`,
+				`server.domains[0]: must be a host name, got "https://bitsgofer.com"`,
+				`server.canonical_host: must be a host name, got "bitsgofer.com:443"`,
+				`server.redirects[0]: both from and to are required`,
+				`server.redirects[1]: status must be 301, 302, 307 or 308, got 200`,
+				`server.cache_control: keys must be extensions starting with a dot, got "html"`,
+				`server.security.overrides[0].path: must be a path pattern starting with /, got "resume"`,
+				`server.access_log.format: must be common, combined or json, got "apache"`,
+				`server.access_log.trusted_proxies[0]: must be an IP address or CIDR range, got "localhost"`,
+				`server.access_log: max_size_mb, rotate_every and max_backups must not be negative`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(tc.fname)
+			if err == nil {
+				t.Fatalf("want Load() to return an error, got none")
+			}
+			for _, want := range tc.expected {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("want error to contain %q, got= %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRedirections(t *testing.T) {
+	c, err := Load("testdata/full.yaml")
+	if err != nil {
+		t.Fatalf("want Load() to return no error, got= %v", err)
+	}
+
+	rds, err := c.Redirections()
+	if err != nil {
+		t.Fatalf("want Redirections() to return no error, got= %v", err)
+	}
+
+	from, _ := url.Parse("http://old.bitsgofer.com/")
+	to, _ := url.Parse("https://bitsgofer.com/")
+	oldBlog, _ := url.Parse("https://bitsgofer.com/old-blog/*")
+	year, _ := url.Parse("/2018/*")
+	expected := redirect.Redirections{
+		redirect.Redirection{FromURL: *from, ToURL: *to},
+		redirect.Redirection{FromURL: *oldBlog, ToURL: *year, Status: 302},
+	}
+	if want, got := expected, rds; !cmp.Equal(want, got) {
+		t.Errorf("mismatched Redirections\n  want= %#v\n   got= %#v", want, got)
+	}
+}
+
+func TestSecurityPolicy(t *testing.T) {
+	c, err := Load("testdata/full.yaml")
+	if err != nil {
+		t.Fatalf("want Load() to return no error, got= %v", err)
+	}
+
+	p, overrides := c.SecurityPolicy()
+	expected := security.DefaultPolicy
+	expected.HSTSMaxAge = 720 * time.Hour
+	expected.ReferrerPolicy = "no-referrer"
+	expected.PermissionsPolicy = ""
+	if want, got := expected, p; !cmp.Equal(want, got) {
+		t.Errorf("mismatched Policy\n  want= %#v\n   got= %#v", want, got)
+	}
+
+	expectedOverrides := []security.Override{
+		{Path: "/resume", Policy: security.Policy{ContentSecurityPolicy: "default-src 'self' https://fonts.example.com"}},
+	}
+	if want, got := expectedOverrides, overrides; !cmp.Equal(want, got) {
+		t.Errorf("mismatched Overrides\n  want= %#v\n   got= %#v", want, got)
+	}
+}
@@ -0,0 +1,126 @@
+package staticgen
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/exklamationmark/glog"
+	"github.com/exklamationmark/notebook/internal/post"
+	"github.com/pkg/errors"
+)
+
+// AliasesFile is the redirect map generated in the html directory from the aliases
+// of posts, one "/alias /canonical/path" per line (see redirect.ParseFile).
+const AliasesFile = "_aliases"
+
+// alias is a former path of a post, both as in post.CanonicalPath.
+type alias struct {
+	from string
+	to   string
+}
+
+// postAliases returns the aliases of posts, sorted. An alias cannot be the path of
+// another page or the alias of two posts.
+func postAliases(posts []*post.Post) ([]alias, error) {
+	taken := map[string]string{"index": "the index", tagsDir: "the tag list"}
+	for _, p := range posts {
+		taken[p.CanonicalPath()] = p.Filename
+	}
+	tags, _ := groupByTag(posts)
+	for _, tag := range tags {
+		taken[tagPath(tag)] = fmt.Sprintf("tag %q", tag)
+	}
+
+	var res []alias
+	aliased := make(map[string]string)
+	for _, p := range posts {
+		for _, a := range p.Metadata.Aliases {
+			if page, exist := taken[a]; exist {
+				return nil, errors.Errorf("alias %q of %q is the path of %s", a, p.Filename, page)
+			}
+			if other, exist := aliased[a]; exist && other != p.Filename {
+				return nil, errors.Errorf("alias %q is used by both %q and %q", a, other, p.Filename)
+			}
+			aliased[a] = p.Filename
+			res = append(res, alias{from: a, to: p.CanonicalPath()})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].from < res[j].from
+	})
+
+	return res, nil
+}
+
+// AliasPages also generates a page at each alias, redirecting to the post with
+// <meta http-equiv="refresh">, for when the site is not served by notebook.
+func AliasPages(enabled bool) func(*config) {
+	return func(c *config) {
+		c.aliasPages = enabled
+	}
+}
+
+var aliasPageTmpl = template.Must(template.New("alias").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url={{.}}">
+<link rel="canonical" href="{{.}}">
+<title>Redirecting to {{.}}</title>
+</head>
+<body><a href="{{.}}">{{.}}</a></body>
+</html>
+`))
+
+// generateAliases writes AliasesFile and, with c.aliasPages, a page for each alias.
+// Pages don't overwrite files that the previous build didn't generate.
+func generateAliases(outDir string, c config, prev *manifest, aliases []alias) error {
+	var buf bytes.Buffer
+	buf.WriteString("# generated from the aliases of posts, changes are overwritten\n")
+	for _, a := range aliases {
+		fmt.Fprintf(&buf, "/%s /%s\n", a.from, a.to)
+	}
+	fname := outDir + "/" + AliasesFile
+	if err := ioutil.WriteFile(fname, buf.Bytes(), 0664); err != nil {
+		return errors.Wrapf(err, "cannot write %q", fname)
+	}
+	glog.V(0).Infof("generated %s", fname)
+
+	if !c.aliasPages {
+		return nil
+	}
+
+	generated := make(map[string]struct{}, len(prev.Outputs))
+	for _, out := range prev.Outputs {
+		generated[out] = struct{}{}
+	}
+	for _, a := range aliases {
+		output := a.from + ".html"
+		fname := outDir + "/" + output
+		if _, err := os.Stat(fname); err == nil {
+			if _, exist := generated[output]; !exist {
+				return errors.Errorf("cannot generate alias page %q, the file already exists", fname)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(fname), 0776); err != nil {
+			return errors.Wrapf(err, "cannot create parent directory of %q", fname)
+		}
+
+		buf.Reset()
+		if err := aliasPageTmpl.Execute(&buf, c.baseURL+"/"+a.to); err != nil {
+			return errors.Wrapf(err, "cannot render alias page %q", fname)
+		}
+		if err := ioutil.WriteFile(fname, buf.Bytes(), 0664); err != nil {
+			return errors.Wrapf(err, "cannot write %q", fname)
+		}
+		glog.V(1).Infof("generated %s", fname)
+	}
+
+	return nil
+}
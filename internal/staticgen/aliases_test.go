@@ -0,0 +1,142 @@
+package staticgen
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPostAliases(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		aliases     [][]string // of each post of feedPosts()
+		expected    []alias
+		expectedErr string
+	}{
+		{
+			"none",
+			[][]string{nil, nil},
+			nil,
+			"",
+		},
+		{
+			"sorted",
+			[][]string{{"2016/01/02/old", "2015/old"}, {"new"}},
+			[]alias{
+				{from: "2015/old", to: "2016/01/01/old"},
+				{from: "2016/01/02/old", to: "2016/01/01/old"},
+				{from: "new", to: "2018/01/01/new"},
+			},
+			"",
+		},
+		{
+			"path of a post",
+			[][]string{{"2018/01/01/new"}, nil},
+			nil,
+			`alias "2018/01/01/new" of "old.md" is the path of new.md`,
+		},
+		{
+			"path of a tag",
+			[][]string{{"tags/go"}, nil},
+			nil,
+			`alias "tags/go" of "old.md" is the path of tag "go"`,
+		},
+		{
+			"used twice",
+			[][]string{{"post"}, {"post"}},
+			nil,
+			`alias "post" is used by both "old.md" and "new.md"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			posts := feedPosts()
+			for i, p := range posts {
+				p.Filename = p.Metadata.Slug + ".md"
+				p.Metadata.Aliases = tc.aliases[i]
+			}
+
+			got, err := postAliases(posts)
+			switch {
+			case len(tc.expectedErr) < 1 && err != nil:
+				t.Fatalf("want postAliases() to return no error, got= %v", err)
+			case len(tc.expectedErr) > 0 && (err == nil || err.Error() != tc.expectedErr):
+				t.Fatalf("want postAliases() to return error= %v, got= %v", tc.expectedErr, err)
+			}
+			if want := tc.expected; !reflect.DeepEqual(want, got) {
+				t.Errorf("wrong aliases\n  want= %v\n   got= %v", want, got)
+			}
+		})
+	}
+}
+
+func TestGenerateAliases(t *testing.T) {
+	postDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", postDir, err)
+	}
+	defer os.RemoveAll(postDir)
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	writePost := func(aliases string) {
+		content := "---\ntitle: renamed\nslug: renamed\npublished: 2018-01-01T00:00:00Z\n" + aliases + "---\nbody\n"
+		if err := ioutil.WriteFile(postDir+"/renamed.md", []byte(content), 0664); err != nil {
+			t.Fatalf("cannot write post, err= %v", err)
+		}
+	}
+
+	writePost("aliases:\n  - /2017/12/31/original.html\n  - /first-draft/\n")
+	if err := Generate(postDir, "testdata/templates", outDir, BaseURL("https://example.com"), AliasPages(true)); err != nil {
+		t.Fatalf("want Generate() to return no error, got= %v", err)
+	}
+
+	want := "# generated from the aliases of posts, changes are overwritten\n" +
+		"/2017/12/31/original /2018/01/01/renamed\n" +
+		"/first-draft /2018/01/01/renamed\n"
+	if got := string(mustRead(t, outDir+"/"+AliasesFile)); want != got {
+		t.Errorf("wrong %s, want= %q, got= %q", AliasesFile, want, got)
+	}
+	page := string(mustRead(t, outDir+"/2017/12/31/original.html"))
+	for _, want := range []string{
+		`<meta http-equiv="refresh" content="0; url=https://example.com/2018/01/01/renamed">`,
+		`<link rel="canonical" href="https://example.com/2018/01/01/renamed">`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("want alias page to contain %q, got:\n%s", want, page)
+		}
+	}
+	if sitemap := string(mustRead(t, outDir+"/"+sitemapFile)); strings.Contains(sitemap, "first-draft") {
+		t.Errorf("want alias pages to be left out of the sitemap, got:\n%s", sitemap)
+	}
+
+	// removed aliases no longer redirect
+	writePost("aliases:\n  - /first-draft\n")
+	if err := Generate(postDir, "testdata/templates", outDir, AliasPages(true)); err != nil {
+		t.Fatalf("want Generate() to return no error, got= %v", err)
+	}
+	if _, err := os.Stat(outDir + "/2017/12/31"); !os.IsNotExist(err) {
+		t.Errorf("want the page of the removed alias to be deleted, err= %v", err)
+	}
+	if got := string(mustRead(t, outDir+"/"+AliasesFile)); strings.Contains(got, "original") {
+		t.Errorf("want the removed alias to be left out of %s, got= %q", AliasesFile, got)
+	}
+
+	// hand-written pages are not overwritten
+	if err := ioutil.WriteFile(outDir+"/about.html", []byte("about"), 0664); err != nil {
+		t.Fatalf("cannot write about.html, err= %v", err)
+	}
+	writePost("aliases:\n  - /about\n")
+	if err := Generate(postDir, "testdata/templates", outDir, AliasPages(true)); err == nil {
+		t.Errorf("want Generate() to return an error for an alias of a hand-written page, got none")
+	}
+	if want, got := "about", string(mustRead(t, outDir+"/about.html")); want != got {
+		t.Errorf("want about.html to be kept, got= %q", got)
+	}
+}
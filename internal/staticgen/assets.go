@@ -0,0 +1,241 @@
+package staticgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/exklamationmark/glog"
+	"github.com/pkg/errors"
+)
+
+// bundles maps the extension of asset files to the bundle they are concatenated into.
+var bundles = map[string]string{
+	".css": "builtin.css",
+	".js":  "builtin.js",
+}
+
+// minifiers minify the content of each bundle.
+var minifiers = map[string]func([]byte) []byte{
+	".css": minifyCSS,
+	".js":  minifyJS,
+}
+
+// defaultCopiedAssets are copied to htmlDir as-is, unless CopyAssets says otherwise.
+var defaultCopiedAssets = []string{
+	"favicon.ico",
+}
+
+// assetMapFile maps the name of each bundle to its fingerprinted name, e.g
+// builtin.css to builtin.0123456789.css.
+const assetMapFile = "assets.json"
+
+// fingerprintLen is the number of hex characters of the content hash in fingerprinted names.
+const fingerprintLen = 10
+
+// CSSFiles sets the CSS files (relative to the asset directory) concatenated into
+// builtin.css, in order. By default, every *.css file is, in file name order.
+func CSSFiles(names []string) func(*config) {
+	return func(c *config) {
+		c.cssFiles = names
+	}
+}
+
+// JSFiles sets the JS files (relative to the asset directory) concatenated into
+// builtin.js, in order. By default, every *.js file is, in file name order.
+func JSFiles(names []string) func(*config) {
+	return func(c *config) {
+		c.jsFiles = names
+	}
+}
+
+// CopyAssets sets the files (relative to the asset directory) copied as-is,
+// e.g favicons. By default (or with nil), only favicon.ico is.
+func CopyAssets(names []string) func(*config) {
+	return func(c *config) {
+		if names != nil {
+			c.copiedAssets = names
+		}
+	}
+}
+
+// Minify enables minifying generated pages and asset bundles (default).
+func Minify(enabled bool) func(*config) {
+	return func(c *config) {
+		c.minify = enabled
+	}
+}
+
+// GenerateAssets concatenates the CSS and JS files in assetDir into htmlDir/builtin.css
+// and htmlDir/builtin.js, minifies them and copies favicons.
+// Each bundle is also written under a fingerprinted name (e.g builtin.0123456789.css)
+// that changes with its content, so it can be cached forever; templates get it with
+// {{asset "builtin.css"}}.
+func GenerateAssets(assetDir, htmlDir string, opts ...opt) error {
+	c := config{gzip: true, minify: true, copiedAssets: defaultCopiedAssets}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	htmlDir = strings.TrimRight(htmlDir, "/")
+
+	files := map[string][]string{
+		".css": c.cssFiles,
+		".js":  c.jsFiles,
+	}
+
+	var generated []string
+	assetMap := make(map[string]string, len(bundles))
+	for ext, bundle := range bundles {
+		names := files[ext]
+		if len(names) == 0 {
+			var err error
+			if names, err = listAssets(assetDir, ext); err != nil {
+				return err
+			}
+		}
+
+		content, err := concatAssets(assetDir, names)
+		if err != nil {
+			return errors.Wrapf(err, "cannot generate %s", bundle)
+		}
+		if c.minify {
+			content = minifiers[ext](content)
+		}
+
+		fingerprinted := fingerprint(bundle, content)
+		for _, name := range []string{bundle, fingerprinted} {
+			fname := htmlDir + "/" + name
+			if err := ioutil.WriteFile(fname, content, 0664); err != nil {
+				return errors.Wrapf(err, "cannot write %q", fname)
+			}
+			glog.V(0).Infof("generated %s", fname)
+			generated = append(generated, name)
+		}
+		assetMap[bundle] = fingerprinted
+
+		if err := removeOldFingerprints(htmlDir, bundle, fingerprinted); err != nil {
+			return err
+		}
+	}
+	if err := saveAssetMap(htmlDir, assetMap); err != nil {
+		return err
+	}
+	if _, err := compressOutputs(htmlDir, c, generated); err != nil {
+		return errors.Wrapf(err, "cannot compress assets")
+	}
+
+	for _, name := range c.copiedAssets {
+		src := filepath.Join(assetDir, name)
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read asset %q", src)
+		}
+
+		fname := htmlDir + "/" + filepath.Base(name)
+		if err := ioutil.WriteFile(fname, b, 0664); err != nil {
+			return errors.Wrapf(err, "cannot write %q", fname)
+		}
+		glog.V(0).Infof("copied %s", fname)
+	}
+
+	return nil
+}
+
+// listAssets returns the names of the files in assetDir with extension ext, in file name order.
+func listAssets(assetDir, ext string) ([]string, error) {
+	infos, err := ioutil.ReadDir(assetDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list assets in %q", assetDir)
+	}
+
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() && filepath.Ext(info.Name()) == ext {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func concatAssets(assetDir string, names []string) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, name := range names {
+		fname := filepath.Join(assetDir, name)
+		b, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read asset %q", fname)
+		}
+		buf.Write(b)
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// fingerprint inserts the hash of content in name, before its extension.
+func fingerprint(name string, content []byte) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hashBytes(content)[:fingerprintLen] + ext
+}
+
+// removeOldFingerprints deletes the previous versions of a bundle (and their compressed siblings).
+func removeOldFingerprints(htmlDir, bundle, current string) error {
+	ext := filepath.Ext(bundle)
+	pattern := htmlDir + "/" + strings.TrimSuffix(bundle, ext) + ".*" + ext + "*"
+	fnames, err := filepath.Glob(pattern)
+	if err != nil {
+		return errors.Wrapf(err, "cannot list old versions of %q", bundle)
+	}
+
+	for _, fname := range fnames {
+		if strings.HasPrefix(filepath.Base(fname), current) {
+			continue
+		}
+		if err := os.Remove(fname); err != nil {
+			return errors.Wrapf(err, "cannot remove old version %q", fname)
+		}
+		glog.V(0).Infof("removed old %s", fname)
+	}
+
+	return nil
+}
+
+func saveAssetMap(htmlDir string, assetMap map[string]string) error {
+	b, err := json.MarshalIndent(assetMap, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "cannot encode asset map")
+	}
+
+	fname := htmlDir + "/" + assetMapFile
+	if err := ioutil.WriteFile(fname, b, 0664); err != nil {
+		return errors.Wrapf(err, "cannot write asset map %q", fname)
+	}
+
+	return nil
+}
+
+// loadAssetMap reads the fingerprinted names written by GenerateAssets.
+// Without one (e.g assets were never generated), assets keep their names.
+func loadAssetMap(htmlDir string) (map[string]string, error) {
+	fname := htmlDir + "/" + assetMapFile
+	b, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read asset map %q", fname)
+	}
+
+	assetMap := make(map[string]string)
+	if err := json.Unmarshal(b, &assetMap); err != nil {
+		return nil, errors.Wrapf(err, "cannot decode asset map %q", fname)
+	}
+
+	return assetMap, nil
+}
@@ -0,0 +1,141 @@
+package staticgen
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAssets(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		opts     []opt
+		expected map[string]string
+	}{
+		{
+			name: "default",
+			expected: map[string]string{
+				"builtin.css": "a{}b{}",
+				"builtin.js":  "var x;\n",
+				"favicon.ico": "\x00\x01",
+			},
+		},
+		{
+			name: "not minified",
+			opts: []opt{Minify(false)},
+			expected: map[string]string{
+				"builtin.css": "a{}\nb{}\n",
+				"builtin.js":  "var x;\n",
+			},
+		},
+		{
+			name: "configured order",
+			opts: []opt{CSSFiles([]string{"b.css", "a.css"}), JSFiles([]string{"x.js", "x.js"})},
+			expected: map[string]string{
+				"builtin.css": "b{}a{}",
+				"builtin.js":  "var x;\nvar x;\n",
+			},
+		},
+		{
+			name: "copied assets",
+			opts: []opt{CopyAssets([]string{"notes.txt"})},
+			expected: map[string]string{
+				"notes.txt": "ignored",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+			if err != nil {
+				t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+			}
+			defer os.RemoveAll(outDir)
+
+			if err := GenerateAssets("testdata/assets", outDir, tc.opts...); err != nil {
+				t.Fatalf("want GenerateAssets() to return no error, got= %v", err)
+			}
+
+			for fname, expected := range tc.expected {
+				b, err := ioutil.ReadFile(outDir + "/" + fname)
+				if err != nil {
+					t.Errorf("cannot read %s, err= %v", fname, err)
+					continue
+				}
+				if want, got := expected, string(b); want != got {
+					t.Errorf("wrong %s content, want= %q, got= %q", fname, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateAssetsMissingFile(t *testing.T) {
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	err = GenerateAssets("testdata/assets", outDir, CSSFiles([]string{"a.css", "missing.css"}))
+	if err == nil || !strings.Contains(err.Error(), "missing.css") {
+		t.Errorf("want error about the missing asset, got= %v", err)
+	}
+}
+
+func TestGenerateAssetsFingerprint(t *testing.T) {
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	old := outDir + "/builtin.0000000000.css"
+	for _, fname := range []string{old, old + ".gz"} {
+		if err := ioutil.WriteFile(fname, []byte("old"), 0664); err != nil {
+			t.Fatalf("cannot write %s, err= %v", fname, err)
+		}
+	}
+
+	if err := GenerateAssets("testdata/assets", outDir); err != nil {
+		t.Fatalf("want GenerateAssets() to return no error, got= %v", err)
+	}
+
+	assets, err := loadAssetMap(outDir)
+	if err != nil {
+		t.Fatalf("want loadAssetMap() to return no error, got= %v", err)
+	}
+	expected := map[string]string{
+		"builtin.css": fingerprint("builtin.css", []byte("a{}b{}")),
+		"builtin.js":  fingerprint("builtin.js", []byte("var x;\n")),
+	}
+	if !reflect.DeepEqual(expected, assets) {
+		t.Errorf("wrong asset map, want= %v, got= %v", expected, assets)
+	}
+	for bundle, fingerprinted := range assets {
+		if !regexp.MustCompile(`^builtin\.[0-9a-f]{10}\.(css|js)$`).MatchString(fingerprinted) {
+			t.Errorf("wrong fingerprinted name for %s, got= %q", bundle, fingerprinted)
+		}
+		for _, name := range []string{fingerprinted, fingerprinted + ".gz"} {
+			if _, err := os.Stat(outDir + "/" + name); err != nil {
+				t.Errorf("want %s to be generated, err= %v", name, err)
+			}
+		}
+	}
+	for _, fname := range []string{old, old + ".gz"} {
+		if _, err := os.Stat(fname); !os.IsNotExist(err) {
+			t.Errorf("want old version %s to be removed, err= %v", fname, err)
+		}
+	}
+}
+
+func TestLoadAssetMapMissing(t *testing.T) {
+	assets, err := loadAssetMap("testdata")
+	if err != nil || len(assets) > 0 {
+		t.Errorf("want empty asset map without %s, got= %v, err= %v", assetMapFile, assets, err)
+	}
+}
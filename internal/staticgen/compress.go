@@ -0,0 +1,98 @@
+package staticgen
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/exklamationmark/glog"
+	"github.com/pkg/errors"
+)
+
+// compressibleExts are the extensions of outputs worth compressing;
+// images and fonts already are.
+var compressibleExts = map[string]bool{
+	".html": true,
+	".css":  true,
+	".js":   true,
+	".xml":  true,
+	".atom": true,
+	".rss":  true,
+	".txt":  true,
+	".svg":  true,
+}
+
+// Gzip enables writing a .gz sibling of every compressible output (default).
+func Gzip(enabled bool) func(*config) {
+	return func(c *config) {
+		c.gzip = enabled
+	}
+}
+
+// compressOutputs writes the .gz siblings of outputs (relative to htmlDir),
+// skipping the ones already newer than their source.
+// It returns the siblings, relative to htmlDir.
+func compressOutputs(htmlDir string, c config, outputs []string) ([]string, error) {
+	if !c.gzip {
+		return nil, nil
+	}
+
+	var siblings []string
+	for _, out := range outputs {
+		if !compressibleExts[filepath.Ext(out)] {
+			continue
+		}
+
+		src := htmlDir + "/" + out
+		if err := writeCompressed(src, src+".gz"); err != nil {
+			return nil, err
+		}
+		siblings = append(siblings, out+".gz")
+	}
+
+	return siblings, nil
+}
+
+func writeCompressed(src, dst string) error {
+	srcStat, err := os.Stat(src)
+	if err != nil {
+		return errors.Wrapf(err, "cannot stat %q", src)
+	}
+	if dstStat, err := os.Stat(dst); err == nil && !dstStat.ModTime().Before(srcStat.ModTime()) {
+		return nil // up to date
+	}
+
+	if err := gzipFile(src, dst); err != nil {
+		return errors.Wrapf(err, "cannot compress %q", src)
+	}
+	glog.V(1).Infof("compressed %s", dst)
+
+	return nil
+}
+
+func gzipFile(src, dst string) error {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
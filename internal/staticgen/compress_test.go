@@ -0,0 +1,93 @@
+package staticgen
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func readGzip(t *testing.T, fname string) string {
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatalf("cannot open %s, err= %v", fname, err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("%s is not gzipped, err= %v", fname, err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("cannot decompress %s, err= %v", fname, err)
+	}
+
+	return string(b)
+}
+
+func TestCompressOutputs(t *testing.T) {
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	files := map[string]string{
+		"index.html": "<p>index</p>",
+		"style.css":  "a{}",
+		"logo.png":   "\x89PNG",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(outDir+"/"+name, []byte(content), 0664); err != nil {
+			t.Fatalf("cannot write %s, err= %v", name, err)
+		}
+	}
+	outputs := []string{"index.html", "logo.png", "style.css"}
+	c := config{gzip: true}
+
+	siblings, err := compressOutputs(outDir, c, outputs)
+	if err != nil {
+		t.Fatalf("want compressOutputs() to return no error, got= %v", err)
+	}
+	if want, got := []string{"index.html.gz", "style.css.gz"}, siblings; !reflect.DeepEqual(want, got) {
+		t.Errorf("wrong siblings, want= %v, got= %v", want, got)
+	}
+	for _, name := range []string{"index.html", "style.css"} {
+		if want, got := files[name], readGzip(t, outDir+"/"+name+".gz"); want != got {
+			t.Errorf("wrong %s.gz content, want= %q, got= %q", name, want, got)
+		}
+	}
+	if _, err := os.Stat(outDir + "/logo.png.gz"); !os.IsNotExist(err) {
+		t.Errorf("want images to be left uncompressed, err= %v", err)
+	}
+
+	// unchanged outputs are not compressed again, changed ones are
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(outDir+"/index.html", old, old)
+	os.Chtimes(outDir+"/index.html.gz", old, old)
+	if err := ioutil.WriteFile(outDir+"/style.css", []byte("b{}"), 0664); err != nil {
+		t.Fatalf("cannot update style.css, err= %v", err)
+	}
+	os.Chtimes(outDir+"/style.css", time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+
+	if _, err := compressOutputs(outDir, c, outputs); err != nil {
+		t.Fatalf("want compressOutputs() to return no error, got= %v", err)
+	}
+	stat, _ := os.Stat(outDir + "/index.html.gz")
+	if !stat.ModTime().Equal(old) {
+		t.Errorf("want up to date index.html.gz to be left alone, modified at %v", stat.ModTime())
+	}
+	if want, got := "b{}", readGzip(t, outDir+"/style.css.gz"); want != got {
+		t.Errorf("want style.css.gz to be updated, want= %q, got= %q", want, got)
+	}
+}
+
+func TestCompressOutputsDisabled(t *testing.T) {
+	siblings, err := compressOutputs("testdata", config{}, []string{"test.md", "index.html"})
+	if err != nil || len(siblings) > 0 {
+		t.Errorf("want nothing compressed, got= %v, err= %v", siblings, err)
+	}
+}
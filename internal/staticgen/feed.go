@@ -0,0 +1,255 @@
+package staticgen
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/exklamationmark/glog"
+	"github.com/exklamationmark/notebook/internal/post"
+	"github.com/pkg/errors"
+)
+
+const (
+	atomNS = "http://www.w3.org/2005/Atom"
+	dcNS   = "http://purl.org/dc/elements/1.1/"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	NS      string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+// feed is the common description of a feed, before it is encoded as Atom or RSS.
+type feed struct {
+	title string
+	path  string // path of the feed, without extension (e.g "feed", "tags/go")
+	posts []*post.Post
+}
+
+func generateFeeds(outDir string, c config, posts []*post.Post) error {
+	feeds := []feed{{title: c.title, path: "feed", posts: posts}}
+	if c.tagFeeds {
+		tags, byTag := groupByTag(posts)
+		for _, tag := range tags {
+			feeds = append(feeds, feed{
+				title: c.title + " - " + tag,
+				path:  tagPath(tag),
+				posts: byTag[tag],
+			})
+		}
+	}
+
+	for _, f := range feeds {
+		sorted := newestFirst(f.posts)
+		if c.feedLimit > 0 && len(sorted) > c.feedLimit {
+			sorted = sorted[:c.feedLimit]
+		}
+
+		parentPath := filepath.Dir(outDir + "/" + f.path)
+		if err := os.MkdirAll(parentPath, 0776); err != nil {
+			return errors.Wrapf(err, "cannot create parent directory %q", parentPath)
+		}
+
+		atomFile := outDir + "/" + f.path + ".atom"
+		if err := writeXML(atomFile, buildAtom(c.baseURL, f.title, f.path, sorted)); err != nil {
+			return errors.Wrapf(err, "cannot write atom feed")
+		}
+		glog.V(0).Infof("generated %s", atomFile)
+
+		rssFile := outDir + "/" + f.path + ".rss"
+		if err := writeXML(rssFile, buildRSS(c.baseURL, f.title, f.path, sorted)); err != nil {
+			return errors.Wrapf(err, "cannot write rss feed")
+		}
+		glog.V(0).Infof("generated %s", rssFile)
+	}
+
+	return nil
+}
+
+func newestFirst(posts []*post.Post) []*post.Post {
+	sorted := make([]*post.Post, len(posts))
+	copy(sorted, posts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		first := sorted[i].Metadata.PublishedAt
+		second := sorted[j].Metadata.PublishedAt
+		return first.After(second)
+	})
+
+	return sorted
+}
+
+func lastUpdated(posts []*post.Post) time.Time {
+	var latest time.Time
+	for _, p := range posts {
+		if p.LastModified().After(latest) {
+			latest = p.LastModified()
+		}
+	}
+
+	return latest
+}
+
+func buildAtom(baseURL, title, path string, posts []*post.Post) *atomFeed {
+	self := baseURL + "/" + path + ".atom"
+	f := &atomFeed{
+		NS:    atomNS,
+		Title: title,
+		ID:    self, // unique to each feed, as readers tell feeds apart by id
+		Links: []atomLink{
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+			{Href: baseURL + "/"},
+		},
+		Updated: lastUpdated(posts).Format(time.RFC3339),
+	}
+
+	for _, p := range posts {
+		link := baseURL + "/" + p.CanonicalPath()
+		entry := atomEntry{
+			Title:     p.Metadata.Title,
+			ID:        link,
+			Link:      atomLink{Href: link},
+			Published: p.Metadata.PublishedAt.Format(time.RFC3339),
+			Updated:   p.LastModified().Format(time.RFC3339),
+			Content:   atomContent{Type: "html", Body: string(p.HTML)},
+		}
+		if len(p.Metadata.Author) > 0 {
+			entry.Author = &atomAuthor{Name: p.Metadata.Author}
+		}
+		for _, tag := range p.Metadata.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+
+		f.Entries = append(f.Entries, entry)
+	}
+
+	return f
+}
+
+func buildRSS(baseURL, title, path string, posts []*post.Post) *rssFeed {
+	f := &rssFeed{
+		Version: "2.0",
+		AtomNS:  atomNS,
+		DCNS:    dcNS,
+		Channel: rssChannel{
+			Title:         title,
+			Link:          baseURL + "/",
+			Description:   title,
+			LastBuildDate: lastUpdated(posts).Format(time.RFC1123Z),
+			Self: rssLink{
+				Href: baseURL + "/" + path + ".rss",
+				Rel:  "self",
+				Type: "application/rss+xml",
+			},
+		},
+	}
+
+	for _, p := range posts {
+		link := baseURL + "/" + p.CanonicalPath()
+		f.Channel.Items = append(f.Channel.Items, rssItem{
+			Title:       p.Metadata.Title,
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: true, ID: link},
+			PubDate:     p.Metadata.PublishedAt.Format(time.RFC1123Z),
+			Creator:     p.Metadata.Author,
+			Categories:  p.Metadata.Tags,
+			Description: string(p.HTML),
+		})
+	}
+
+	return f
+}
+
+func writeXML(fname string, v interface{}) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return errors.Wrapf(err, "cannot open file to write")
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(xml.Header); err != nil {
+		return errors.Wrapf(err, "cannot write xml header")
+	}
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return errors.Wrapf(err, "cannot encode xml")
+	}
+
+	return nil
+}
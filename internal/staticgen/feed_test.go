@@ -0,0 +1,155 @@
+package staticgen
+
+import (
+	"encoding/xml"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/exklamationmark/notebook/internal/post"
+)
+
+func feedPosts() []*post.Post {
+	return []*post.Post{
+		{
+			Metadata: post.Metadata{
+				Title:       "Old",
+				Slug:        "old",
+				Author:      "mark",
+				PublishedAt: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+				Tags:        []string{"go", "terraform"},
+			},
+			HTML: template.HTML("<p>old</p>"),
+		},
+		{
+			Metadata: post.Metadata{
+				Title:       "New",
+				Slug:        "new",
+				Author:      "mark",
+				PublishedAt: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+				Tags:        []string{"terraform"},
+			},
+			HTML: template.HTML("<p>new</p>"),
+		},
+	}
+}
+
+func TestGenerateFeeds(t *testing.T) {
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	c := config{baseURL: "https://example.com", tagFeeds: true}
+	if err := generateFeeds(outDir, c, feedPosts()); err != nil {
+		t.Fatalf("want generateFeeds() to return no error, got= %v", err)
+	}
+
+	for _, fname := range []string{
+		"feed.atom", "feed.rss",
+		"tags/go.atom", "tags/go.rss",
+		"tags/terraform.atom", "tags/terraform.rss",
+	} {
+		if _, err := os.Stat(outDir + "/" + fname); err != nil {
+			t.Errorf("want %s to be generated, err= %v", fname, err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(outDir + "/feed.atom")
+	if err != nil {
+		t.Fatalf("cannot read atom feed, err= %v", err)
+	}
+	var atom atomFeed
+	if err := xml.Unmarshal(b, &atom); err != nil {
+		t.Fatalf("cannot decode atom feed, err= %v", err)
+	}
+	if want, got := "2018-01-01T00:00:00Z", atom.Updated; want != got {
+		t.Errorf("wrong feed updated time, want= %v, got= %v", want, got)
+	}
+	if want, got := "https://example.com/feed.atom", atom.ID; want != got {
+		t.Errorf("wrong feed id, want= %v, got= %v", want, got)
+	}
+	var tagAtom atomFeed
+	if err := xml.Unmarshal(mustRead(t, outDir+"/tags/go.atom"), &tagAtom); err != nil {
+		t.Fatalf("cannot decode atom feed, err= %v", err)
+	}
+	if want, got := "https://example.com/tags/go.atom", tagAtom.ID; want != got {
+		t.Errorf("wrong tag feed id, want= %v, got= %v", want, got)
+	}
+	var links []string
+	for _, e := range atom.Entries {
+		links = append(links, e.Link.Href)
+	}
+	if want, got := []string{"https://example.com/2018/01/01/new", "https://example.com/2016/01/01/old"}, links; !cmp.Equal(want, got) {
+		t.Errorf("wrong atom entries\n  want= %v\n   got= %v", want, got)
+	}
+	if want, got := "<p>new</p>", atom.Entries[0].Content.Body; want != got {
+		t.Errorf("wrong atom content, want= %q, got= %q", want, got)
+	}
+
+	b, err = ioutil.ReadFile(outDir + "/tags/go.rss")
+	if err != nil {
+		t.Fatalf("cannot read rss feed, err= %v", err)
+	}
+	var rss rssFeed
+	if err := xml.Unmarshal(b, &rss); err != nil {
+		t.Fatalf("cannot decode rss feed, err= %v", err)
+	}
+	if want, got := 1, len(rss.Channel.Items); want != got {
+		t.Fatalf("wrong number of rss items, want= %v, got= %v", want, got)
+	}
+	item := rss.Channel.Items[0]
+	if want, got := "Fri, 01 Jan 2016 00:00:00 +0000", item.PubDate; want != got {
+		t.Errorf("wrong rss pubDate, want= %v, got= %v", want, got)
+	}
+	if want, got := []string{"go", "terraform"}, item.Categories; !cmp.Equal(want, got) {
+		t.Errorf("wrong rss categories, want= %v, got= %v", want, got)
+	}
+}
+
+func TestGenerateFeedsWithoutTagFeeds(t *testing.T) {
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	if err := generateFeeds(outDir, config{baseURL: "https://example.com"}, feedPosts()); err != nil {
+		t.Fatalf("want generateFeeds() to return no error, got= %v", err)
+	}
+	if _, err := os.Stat(outDir + "/tags/go.atom"); !os.IsNotExist(err) {
+		t.Errorf("want no tag feeds to be generated, err= %v", err)
+	}
+}
+
+func TestGenerateFeedsLimit(t *testing.T) {
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	c := config{title: "Notebook", baseURL: "https://example.com", feedLimit: 1}
+	if err := generateFeeds(outDir, c, feedPosts()); err != nil {
+		t.Fatalf("want generateFeeds() to return no error, got= %v", err)
+	}
+
+	var atom atomFeed
+	if err := xml.Unmarshal(mustRead(t, outDir+"/feed.atom"), &atom); err != nil {
+		t.Fatalf("cannot decode atom feed, err= %v", err)
+	}
+	if want, got := "Notebook", atom.Title; want != got {
+		t.Errorf("wrong feed title, want= %v, got= %v", want, got)
+	}
+	if want, got := 1, len(atom.Entries); want != got {
+		t.Fatalf("wrong number of entries, want= %v, got= %v", want, got)
+	}
+	if want, got := "New", atom.Entries[0].Title; want != got {
+		t.Errorf("want newest entry to be kept, got= %v", got)
+	}
+}
@@ -0,0 +1,137 @@
+package staticgen
+
+import (
+	"encoding/json"
+	"html/template"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	markdown "gopkg.in/russross/blackfriday.v2"
+)
+
+// dateLayouts are shorthands accepted by the date template function,
+// on top of any Go time layout.
+var dateLayouts = map[string]string{
+	"short":   "2006-01-02",
+	"long":    "January 2, 2006",
+	"rfc3339": time.RFC3339,
+	"rfc1123": time.RFC1123,
+}
+
+const wordsPerMinute = 200
+
+// funcMap returns the functions available to every template staticgen renders.
+func funcMap(c config) template.FuncMap {
+	return template.FuncMap{
+		"date":        formatDate,
+		"tagURL":      tagURL,
+		"absURL":      absURL(c.baseURL),
+		"truncate":    truncate,
+		"excerpt":     excerpt,
+		"readingTime": readingTime,
+		"markdownify": markdownify,
+		"slugify":     tagSlug,
+		"json":        toJSON,
+		"asset":       assetURL(c.assets),
+	}
+}
+
+// assetURL returns the URL of an asset, fingerprinted if GenerateAssets did.
+// Usage: <link rel="stylesheet" href="{{asset "builtin.css"}}"/>
+func assetURL(assets map[string]string) func(string) string {
+	return func(name string) string {
+		name = strings.TrimLeft(name, "/")
+		if fingerprinted, exist := assets[name]; exist {
+			return "/" + fingerprinted
+		}
+
+		return "/" + name
+	}
+}
+
+// formatDate formats t with a named layout (e.g short, long) or a Go time layout.
+// Usage: {{date "long" .Post.Metadata.PublishedAt}}
+func formatDate(layout string, t time.Time) string {
+	if named, exist := dateLayouts[layout]; exist {
+		layout = named
+	}
+
+	return t.Format(layout)
+}
+
+func tagURL(tag string) string {
+	return "/" + tagPath(tag)
+}
+
+func absURL(baseURL string) func(string) string {
+	return func(path string) string {
+		if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+			return path
+		}
+
+		return strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(path, "/")
+	}
+}
+
+// truncate shortens s to at most n characters, ending with "…" if it was cut.
+// Usage: {{truncate 100 .Title}}
+func truncate(n int, s string) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+var (
+	htmlTags   = regexp.MustCompile(`<[^>]*>`)
+	whitespace = regexp.MustCompile(`\s+`)
+)
+
+func plainText(html template.HTML) string {
+	text := htmlTags.ReplaceAllString(string(html), " ")
+	return strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+}
+
+// excerpt returns the first n words of a rendered post, without markup.
+// Usage: {{excerpt 50 .HTML}}
+func excerpt(n int, html template.HTML) string {
+	words := strings.Fields(plainText(html))
+	if len(words) <= n {
+		return strings.Join(words, " ")
+	}
+
+	return strings.Join(words[:n], " ") + "…"
+}
+
+// readingTime estimates how many minutes it takes to read a rendered post.
+func readingTime(html template.HTML) int {
+	words := len(strings.Fields(plainText(html)))
+	minutes := (words + wordsPerMinute - 1) / wordsPerMinute
+	if minutes < 1 {
+		return 1
+	}
+
+	return minutes
+}
+
+func markdownify(s string) template.HTML {
+	return template.HTML(markdown.Run([]byte(s)))
+}
+
+// toJSON encodes v, e.g for JSON-LD metadata in <script> tags.
+func toJSON(v interface{}) (template.JS, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot encode %T as json", v)
+	}
+
+	return template.JS(b), nil
+}
@@ -0,0 +1,170 @@
+package staticgen
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"time"
+)
+
+func TestFormatDate(t *testing.T) {
+	at := time.Date(2018, 8, 5, 15, 47, 0, 0, time.UTC)
+	var testCases = []struct {
+		layout   string
+		expected string
+	}{
+		{"short", "2018-08-05"},
+		{"long", "August 5, 2018"},
+		{"rfc3339", "2018-08-05T15:47:00Z"},
+		{"02 Jan 06", "05 Aug 18"},
+	}
+
+	for _, tc := range testCases {
+		if want, got := tc.expected, formatDate(tc.layout, at); want != got {
+			t.Errorf("wrong date for layout %q, want= %q, got= %q", tc.layout, want, got)
+		}
+	}
+}
+
+func TestTagURL(t *testing.T) {
+	if want, got := "/tags/programming-conference", tagURL("programming, conference"); want != got {
+		t.Errorf("wrong tag URL, want= %q, got= %q", want, got)
+	}
+}
+
+func TestAbsURL(t *testing.T) {
+	var testCases = []struct {
+		baseURL  string
+		path     string
+		expected string
+	}{
+		{"https://example.com", "/about.html", "https://example.com/about.html"},
+		{"https://example.com/", "tags/go", "https://example.com/tags/go"},
+		{"https://example.com", "", "https://example.com/"},
+		{"https://example.com", "https://other.com/x", "https://other.com/x"},
+	}
+
+	for _, tc := range testCases {
+		if want, got := tc.expected, absURL(tc.baseURL)(tc.path); want != got {
+			t.Errorf("wrong absolute URL for %q, want= %q, got= %q", tc.path, want, got)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	var testCases = []struct {
+		n        int
+		s        string
+		expected string
+	}{
+		{10, "short", "short"},
+		{5, "exactly", "exac…"},
+		{4, "Tại sao", "Tại…"},
+		{0, "gone", ""},
+	}
+
+	for _, tc := range testCases {
+		if want, got := tc.expected, truncate(tc.n, tc.s); want != got {
+			t.Errorf("wrong truncate(%d, %q), want= %q, got= %q", tc.n, tc.s, want, got)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	html := template.HTML("<h1>Title</h1>\n<p>one <em>two</em>\nthree four</p>")
+	var testCases = []struct {
+		n        int
+		expected string
+	}{
+		{3, "Title one two…"},
+		{10, "Title one two three four"},
+	}
+
+	for _, tc := range testCases {
+		if want, got := tc.expected, excerpt(tc.n, html); want != got {
+			t.Errorf("wrong excerpt(%d), want= %q, got= %q", tc.n, want, got)
+		}
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	var testCases = []struct {
+		words    int
+		expected int
+	}{
+		{0, 1},
+		{10, 1},
+		{200, 1},
+		{201, 2},
+		{1000, 5},
+	}
+
+	for _, tc := range testCases {
+		html := template.HTML("<p>" + string(bytes.Repeat([]byte("word "), tc.words)) + "</p>")
+		if want, got := tc.expected, readingTime(html); want != got {
+			t.Errorf("wrong reading time for %d words, want= %v, got= %v", tc.words, want, got)
+		}
+	}
+}
+
+func TestMarkdownify(t *testing.T) {
+	if want, got := template.HTML("<p>some <em>text</em></p>\n"), markdownify("some *text*"); want != got {
+		t.Errorf("wrong markdownify, want= %q, got= %q", want, got)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	slugify := funcMap(config{})["slugify"].(func(string) string)
+	if want, got := "first-look-at-terraform", slugify("First look at Terraform!"); want != got {
+		t.Errorf("wrong slugify, want= %q, got= %q", want, got)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	js, err := toJSON(map[string]string{"title": "</script>"})
+	if err != nil {
+		t.Fatalf("want toJSON() to return no error, got= %v", err)
+	}
+	if want, got := template.JS(`{"title":"\u003c/script\u003e"}`), js; want != got {
+		t.Errorf("wrong json, want= %q, got= %q", want, got)
+	}
+
+	if _, err := toJSON(make(chan int)); err == nil {
+		t.Errorf("want error encoding a channel, got none")
+	}
+}
+
+func TestAssetURL(t *testing.T) {
+	asset := assetURL(map[string]string{"builtin.css": "builtin.0123456789.css"})
+
+	var testCases = []struct {
+		name     string
+		expected string
+	}{
+		{"builtin.css", "/builtin.0123456789.css"},
+		{"/builtin.css", "/builtin.0123456789.css"},
+		{"favicon.ico", "/favicon.ico"},
+	}
+	for _, tc := range testCases {
+		if want, got := tc.expected, asset(tc.name); want != got {
+			t.Errorf("wrong URL for %q, want= %q, got= %q", tc.name, want, got)
+		}
+	}
+}
+
+func TestFuncMapInTemplate(t *testing.T) {
+	tmpl, err := template.New("t").Funcs(funcMap(config{baseURL: "https://example.com"})).
+		Parse(`{{date "short" .At}} {{absURL "/x"}} {{tagURL "Go"}}`)
+	if err != nil {
+		t.Fatalf("cannot parse template, err= %v", err)
+	}
+
+	var buf bytes.Buffer
+	data := struct{ At time.Time }{time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("cannot execute template, err= %v", err)
+	}
+	if want, got := "2018-01-02 https://example.com/x /tags/go", buf.String(); want != got {
+		t.Errorf("wrong output, want= %q, got= %q", want, got)
+	}
+}
@@ -2,60 +2,186 @@ package staticgen
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
-	"html/template"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/exklamationmark/glog"
 	"github.com/exklamationmark/notebook/internal/post"
 	"github.com/pkg/errors"
 )
 
-func Generate(postDir, postTemplate, htmlDir string) error {
-	tmpl, err := template.ParseFiles(postTemplate)
+type config struct {
+	title      string
+	author     string
+	feedLimit  int
+	baseURL    string
+	tagFeeds   bool
+	robotsFile string
+	drafts     bool
+	future     bool
+	jobs       int
+	gzip       bool
+	assets     map[string]string // fingerprinted names of assets
+	minify     bool
+	aliasPages bool
+
+	// assets
+	cssFiles     []string
+	jsFiles      []string
+	copiedAssets []string
+}
+
+type opt func(*config)
+
+func Generate(postDir, templateDir, htmlDir string, opts ...opt) error {
+	c := config{jobs: 1, gzip: true, minify: true}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	c.baseURL = strings.TrimRight(c.baseURL, "/")
+	htmlDir = strings.TrimRight(htmlDir, "/")
+
+	assets, err := loadAssetMap(htmlDir)
 	if err != nil {
-		return errors.Wrapf(err, "cannot parse post template %q", postTemplate)
+		return err
 	}
+	c.assets = assets
 
-	htmlDir = strings.TrimRight(htmlDir, "/")
+	l, err := loadLayouts(templateDir, funcMap(c))
+	if err != nil {
+		return errors.Wrapf(err, "cannot load templates from %q", templateDir)
+	}
+	l.minify = c.minify
+
+	// rendered posts depend on the templates and on the config used to render them,
+	// including the asset names they link to
+	assetNames, _ := json.Marshal(c.assets)
+	buildHash := hashBytes([]byte(strings.Join([]string{
+		l.hash, c.title, c.baseURL, c.author, string(assetNames), fmt.Sprint(c.minify),
+	}, "\x00")))
+	cache := &buildCache{
+		postDir: postDir,
+		prev:    loadManifest(htmlDir),
+		cur:     newManifest(buildHash),
+	}
 
-	posts := make([]*post.Post, 0, 20)
-	if err := filepath.Walk(postDir, processPost(htmlDir, tmpl, &posts)); err != nil {
+	fnames, err := findPosts(postDir)
+	if err != nil {
+		return errors.Wrapf(err, "cannot list posts in %q", postDir)
+	}
+	posts, err := processPosts(htmlDir, c, l, cache, fnames)
+	if err != nil {
 		return errors.Wrapf(err, "cannot process all posts")
 	}
+	aliases, err := postAliases(posts)
+	if err != nil {
+		return err
+	}
 
-	generateIndex(htmlDir, tmpl, posts)
+	if err := generateIndex(htmlDir, c, l, posts); err != nil {
+		return err
+	}
 
-	return nil
+	if err := generateTags(htmlDir, l, posts); err != nil {
+		return errors.Wrapf(err, "cannot generate tag pages")
+	}
+
+	if err := generateFeeds(htmlDir, c, posts); err != nil {
+		return errors.Wrapf(err, "cannot generate feeds")
+	}
+
+	if err := generateSitemap(htmlDir, c, posts); err != nil {
+		return errors.Wrapf(err, "cannot generate sitemap")
+	}
+
+	if err := generateRobots(htmlDir, c); err != nil {
+		return errors.Wrapf(err, "cannot generate robots.txt")
+	}
+
+	if err := generateAliases(htmlDir, c, cache.prev, aliases); err != nil {
+		return errors.Wrapf(err, "cannot generate aliases")
+	}
+
+	cache.cur.Outputs = outputs(c, posts)
+	compressed, err := compressOutputs(htmlDir, c, cache.cur.Outputs)
+	if err != nil {
+		return errors.Wrapf(err, "cannot compress outputs")
+	}
+	cache.cur.Outputs = append(cache.cur.Outputs, compressed...)
+	if err := cache.cur.removeStale(cache.prev, htmlDir); err != nil {
+		return errors.Wrapf(err, "cannot remove stale outputs")
+	}
+
+	return cache.cur.save(htmlDir)
+}
+
+// outputs lists every file generated for posts, relative to the html directory.
+func outputs(c config, posts []*post.Post) []string {
+	res := []string{
+		"index.html",
+		tagsDir + ".html",
+		"feed.atom",
+		"feed.rss",
+		sitemapFile,
+		robotsFile,
+		AliasesFile,
+	}
+	for _, p := range posts {
+		res = append(res, p.CanonicalPath()+".html")
+		if c.aliasPages {
+			for _, a := range p.Metadata.Aliases {
+				res = append(res, a+".html")
+			}
+		}
+	}
+
+	tags, _ := groupByTag(posts)
+	for _, tag := range tags {
+		res = append(res, tagPath(tag)+".html")
+		if c.tagFeeds {
+			res = append(res, tagPath(tag)+".atom", tagPath(tag)+".rss")
+		}
+	}
+
+	return res
+}
+
+// BaseURL sets the absolute URL the site is served from (e.g https://example.com),
+// used to build links in generated feeds and the sitemap.
+func BaseURL(u string) func(*config) {
+	return func(c *config) {
+		c.baseURL = u
+	}
+}
+
+// TagFeeds enables generating an Atom and RSS feed for each tag.
+func TagFeeds(enabled bool) func(*config) {
+	return func(c *config) {
+		c.tagFeeds = enabled
+	}
 }
 
-func generateIndex(outDir string, tmpl *template.Template, posts []*post.Post) error {
+func generateIndex(outDir string, c config, l *layouts, posts []*post.Post) error {
 	sort.Slice(posts, func(i, j int) bool {
 		first := posts[i].Metadata.PublishedAt
 		second := posts[j].Metadata.PublishedAt
 		return first.Before(second)
 	})
 
-	var buf bytes.Buffer
-	buf.WriteString("<h2>Index</h2>\n")
-	buf.WriteString("<ul>\n")
-	for _, p := range posts {
-		path := "/" + p.CanonicalPath()
-		buf.WriteString(fmt.Sprintf("<li><a href=\"%s\">%s</a></li>\n", path, p.Metadata.Title))
-	}
-	buf.WriteString("</ul>\n")
-	p := &post.Post{
-		Metadata: post.Metadata{
-			Title: "Bitsgofer",
-		},
-		HTML: template.HTML(buf.String()),
+	tmpl, err := l.get(layoutIndex)
+	if err != nil {
+		return err
 	}
 
 	fname := outDir + "/index.html"
-	if err := renderPost(fname, p, tmpl); err != nil {
+	data := page{Kind: layoutIndex, Title: c.title, Posts: posts}
+	if err := l.render(fname, tmpl, data); err != nil {
 		return errors.Wrapf(err, "cannot render index")
 	}
 
@@ -63,51 +189,216 @@ func generateIndex(outDir string, tmpl *template.Template, posts []*post.Post) e
 	return nil
 }
 
-func processPost(outDir string, tmpl *template.Template, posts *[]*post.Post) func(string, os.FileInfo, error) error {
-	return func(fname string, stat os.FileInfo, err error) error {
+// buildCache holds the manifests of the previous and current build.
+type buildCache struct {
+	postDir string
+	prev    *manifest
+	cur     *manifest
+}
+
+// findPosts lists the markdown files under postDir, in lexical order.
+func findPosts(postDir string) ([]string, error) {
+	var fnames []string
+	err := filepath.Walk(postDir, func(fname string, stat os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if stat.IsDir() {
 			return nil
 		}
 
-		baseName := stat.Name()
-		ext := filepath.Ext(baseName)
+		ext := filepath.Ext(stat.Name())
 		if !(ext == ".md" || ext == ".markdown") {
 			return nil
 		}
 
+		fnames = append(fnames, fname)
+		return nil
+	})
+
+	return fnames, err
+}
+
+// processed is the result of processing one markdown file. p is nil if the
+// post was skipped (e.g it's a draft).
+type processed struct {
+	key   string
+	entry manifestEntry
+	p     *post.Post
+	err   error
+}
+
+// processPosts parses and renders fnames using a pool of c.jobs workers.
+// Posts are returned in the same order as fnames, and every failure is
+// reported, rather than only the first one.
+func processPosts(outDir string, c config, l *layouts, cache *buildCache, fnames []string) ([]*post.Post, error) {
+	now := time.Now()
+	results := make([]processed, len(fnames))
+
+	jobs := c.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i] = processPost(outDir, c, l, cache, fnames[i], now)
+			}
+		}()
+	}
+	for i := range fnames {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	posts := make([]*post.Post, 0, len(results))
+	var errs buildErrors
+	for _, res := range results {
+		if res.err != nil {
+			errs = append(errs, res.err)
+			continue
+		}
+		if res.p == nil {
+			continue
+		}
+
+		cache.cur.Posts[res.key] = res.entry
+		posts = append(posts, res.p)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return posts, nil
+}
+
+func processPost(outDir string, c config, l *layouts, cache *buildCache, fname string, now time.Time) processed {
+	key, err := filepath.Rel(cache.postDir, fname)
+	if err != nil {
+		return processed{err: errors.Wrapf(err, "cannot get path of %q relative to %q", fname, cache.postDir)}
+	}
+	hash, err := hashFile(fname)
+	if err != nil {
+		return processed{err: err}
+	}
+
+	p, unchanged := cache.cur.reusable(cache.prev, key, hash, outDir)
+	if !unchanged {
 		glog.V(0).Infof("processing: %s", fname)
-		post, err := post.New(fname)
+		p, err = post.New(fname)
 		if err != nil {
-			return errors.Wrapf(err, "cannot create post from %q", fname)
+			return processed{err: errors.Wrapf(err, "cannot create post from %q", fname)}
 		}
-
-		parentPath := fmt.Sprintf("%s/%s", outDir, post.ParentPath())
-		if err := os.MkdirAll(parentPath, 0776); err != nil {
-			return errors.Wrapf(err, "cannot create parent directory %q", parentPath)
+		if len(p.Metadata.Author) < 1 {
+			p.Metadata.Author = c.author
 		}
+	}
+	if p.Metadata.Draft && !c.drafts {
+		glog.V(0).Infof("skipped draft: %s", fname)
+		return processed{}
+	}
+	if p.Metadata.PublishedAt.After(now) && !c.future {
+		glog.V(0).Infof("skipped future post (published %v): %s", p.Metadata.PublishedAt, fname)
+		return processed{}
+	}
 
-		generatedFile := fmt.Sprintf("%s/%s.html", outDir, post.CanonicalPath())
-		if err := renderPost(generatedFile, post, tmpl); err != nil {
-			return err
-		}
+	layout := layoutForPost(p)
+	tmpl, err := l.get(layout)
+	if err != nil {
+		return processed{err: errors.Wrapf(err, "cannot render %q", fname)}
+	}
 
-		*posts = append(*posts, post)
+	output := p.CanonicalPath() + ".html"
+	res := processed{
+		key:   key,
+		entry: manifestEntry{Hash: hash, Output: output, Post: p},
+		p:     p,
+	}
+	if unchanged {
+		glog.V(1).Infof("unchanged: %s", fname)
+		return res
+	}
 
-		glog.V(0).Infof("generated %s", generatedFile)
-		return nil
+	parentPath := fmt.Sprintf("%s/%s", outDir, p.ParentPath())
+	if err := os.MkdirAll(parentPath, 0776); err != nil {
+		return processed{err: errors.Wrapf(err, "cannot create parent directory %q", parentPath)}
 	}
+
+	generatedFile := outDir + "/" + output
+	data := page{Kind: layout, Title: p.Metadata.Title, Post: p}
+	if err := l.render(generatedFile, tmpl, data); err != nil {
+		return processed{err: errors.Wrapf(err, "cannot render %q", fname)}
+	}
+
+	glog.V(0).Infof("generated %s", generatedFile)
+	return res
 }
 
-func renderPost(fname string, p *post.Post, tmpl *template.Template) error {
-	f, err := os.Create(fname)
-	if err != nil {
-		return errors.Wrapf(err, "cannot open file to write")
+// buildErrors collects the errors of every post that failed to build.
+type buildErrors []error
+
+func (errs buildErrors) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("%d post(s) failed to build:", len(errs)))
+	for _, err := range errs {
+		buf.WriteString("\n  - ")
+		buf.WriteString(err.Error())
 	}
-	defer f.Close()
 
-	if err := tmpl.Execute(f, p); err != nil {
-		return errors.Wrapf(err, "cannot execute template post")
+	return buf.String()
+}
+
+// RobotsFile sets the file used as the base of the generated robots.txt.
+// A Sitemap line pointing to the generated sitemap is always appended.
+func RobotsFile(fname string) func(*config) {
+	return func(c *config) {
+		c.robotsFile = fname
 	}
+}
 
-	return nil
+// Drafts includes posts marked with `draft: true` in the generated site.
+func Drafts(enabled bool) func(*config) {
+	return func(c *config) {
+		c.drafts = enabled
+	}
+}
+
+// Future includes posts published in the future in the generated site.
+func Future(enabled bool) func(*config) {
+	return func(c *config) {
+		c.future = enabled
+	}
+}
+
+// Jobs sets how many posts are processed in parallel.
+func Jobs(n int) func(*config) {
+	return func(c *config) {
+		c.jobs = n
+	}
+}
+
+// Title sets the title of the site, used for the index and feeds.
+func Title(title string) func(*config) {
+	return func(c *config) {
+		c.title = title
+	}
+}
+
+// Author sets the author of posts that don't have one in their metadata.
+func Author(name string) func(*config) {
+	return func(c *config) {
+		c.author = name
+	}
+}
+
+// FeedLimit caps the number of entries in each feed. 0 means no limit.
+func FeedLimit(n int) func(*config) {
+	return func(c *config) {
+		c.feedLimit = n
+	}
 }
@@ -1,20 +1,206 @@
 package staticgen
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/pkg/errors"
 )
 
 func TestGenerate(t *testing.T) {
 	postDir := "testdata"
-	postTemplate := "testdata/template/template.html"
+	templateDir := "testdata/templates"
 	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
 	if err != nil {
 		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
 	}
 
-	if err := Generate(postDir, postTemplate, outDir); err != nil {
+	if err := Generate(postDir, templateDir, outDir); err != nil {
 		t.Errorf("want Generate() to return no error, got= %v", err)
 	}
+	if _, err := os.Stat(outDir + "/index.html.gz"); err != nil {
+		t.Errorf("want outputs to be gzipped by default, err= %v", err)
+	}
+}
+
+func TestGenerateMinify(t *testing.T) {
+	for _, minify := range []bool{true, false} {
+		t.Run(fmt.Sprint(minify), func(t *testing.T) {
+			outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+			if err != nil {
+				t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+			}
+			defer os.RemoveAll(outDir)
+
+			if err := Generate("testdata", "testdata/templates", outDir, Minify(minify)); err != nil {
+				t.Fatalf("want Generate() to return no error, got= %v", err)
+			}
+
+			b, err := ioutil.ReadFile(outDir + "/index.html")
+			if err != nil {
+				t.Fatalf("cannot read index.html, err= %v", err)
+			}
+			if want, got := minify, !strings.Contains(string(b), "\n"); want != got {
+				t.Errorf("wrong minification, want= %v, got= %v:\n%s", want, got, b)
+			}
+		})
+	}
+}
+
+func TestGenerateDraftsAndFuture(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		opts         []opt
+		expectDraft  bool
+		expectFuture bool
+	}{
+		{"default", nil, false, false},
+		{"drafts", []opt{Drafts(true)}, true, false},
+		{"future", []opt{Future(true)}, false, true},
+		{"drafts and future", []opt{Drafts(true), Future(true)}, true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+			if err != nil {
+				t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+			}
+			defer os.RemoveAll(outDir)
+
+			if err := Generate("testdata", "testdata/templates", outDir, tc.opts...); err != nil {
+				t.Fatalf("want Generate() to return no error, got= %v", err)
+			}
+
+			_, err = os.Stat(outDir + "/2018/07/22/draft.html")
+			if want, got := tc.expectDraft, err == nil; want != got {
+				t.Errorf("wrong draft generation, want= %v, got= %v (err= %v)", want, got, err)
+			}
+			_, err = os.Stat(outDir + "/2099/01/01/future.html")
+			if want, got := tc.expectFuture, err == nil; want != got {
+				t.Errorf("wrong future post generation, want= %v, got= %v (err= %v)", want, got, err)
+			}
+			if _, err := os.Stat(outDir + "/2018/07/21/untitled.html"); err != nil {
+				t.Errorf("want published post to be generated, err= %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateParallel(t *testing.T) {
+	postDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", postDir, err)
+	}
+	defer os.RemoveAll(postDir)
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	var fnames []string
+	for i := 0; i < 20; i++ {
+		fname := fmt.Sprintf("%s/post-%02d.md", postDir, i)
+		content := fmt.Sprintf("---\ntitle: post %d\nslug: post-%d\npublished: 2018-01-%02dT00:00:00Z\n---\nbody\n", i, i, i+1)
+		if err := ioutil.WriteFile(fname, []byte(content), 0664); err != nil {
+			t.Fatalf("cannot write post, err= %v", err)
+		}
+		fnames = append(fnames, fname)
+	}
+
+	c := config{jobs: 4}
+	l, err := loadLayouts("testdata/templates", funcMap(config{}))
+	if err != nil {
+		t.Fatalf("cannot load layouts, err= %v", err)
+	}
+	cache := &buildCache{postDir: postDir, prev: newManifest(""), cur: newManifest("")}
+
+	posts, err := processPosts(outDir, c, l, cache, fnames)
+	if err != nil {
+		t.Fatalf("want processPosts() to return no error, got= %v", err)
+	}
+	if want, got := len(fnames), len(posts); want != got {
+		t.Fatalf("wrong number of posts, want= %v, got= %v", want, got)
+	}
+	for i, p := range posts {
+		if want, got := fmt.Sprintf("post-%d", i), p.Metadata.Slug; want != got {
+			t.Errorf("posts out of order at %d, want= %v, got= %v", i, want, got)
+		}
+	}
+}
+
+func TestGenerateReportsAllErrors(t *testing.T) {
+	postDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", postDir, err)
+	}
+	defer os.RemoveAll(postDir)
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	copyFile(t, "testdata/test.md", postDir+"/good.md")
+	for _, name := range []string{"bad1.md", "bad2.md"} {
+		if err := ioutil.WriteFile(postDir+"/"+name, []byte("no metadata"), 0664); err != nil {
+			t.Fatalf("cannot write post, err= %v", err)
+		}
+	}
+
+	err = Generate(postDir, "testdata/templates", outDir, Jobs(2))
+	if err == nil {
+		t.Fatalf("want Generate() to return an error, got none")
+	}
+	errs, ok := errors.Cause(err).(buildErrors)
+	if !ok {
+		t.Fatalf("want buildErrors, got= %T (%v)", errors.Cause(err), err)
+	}
+	if want, got := 2, len(errs); want != got {
+		t.Errorf("wrong number of errors, want= %v, got= %v (%v)", want, got, err)
+	}
+	for _, name := range []string{"bad1.md", "bad2.md"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("want error to mention %s, got= %v", name, err)
+		}
+	}
+}
+
+func TestGenerateDefaultAuthor(t *testing.T) {
+	postDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", postDir, err)
+	}
+	defer os.RemoveAll(postDir)
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	copyFile(t, "testdata/test.md", postDir+"/with_author.md")
+	content := "---\ntitle: anonymous\nslug: anonymous\npublished: 2018-01-01T00:00:00Z\n---\nbody\n"
+	if err := ioutil.WriteFile(postDir+"/anonymous.md", []byte(content), 0664); err != nil {
+		t.Fatalf("cannot write post, err= %v", err)
+	}
+
+	err = Generate(postDir, "testdata/templates", outDir, Author("someone"), Title("Notebook"))
+	if err != nil {
+		t.Fatalf("want Generate() to return no error, got= %v", err)
+	}
+
+	m := loadManifest(outDir)
+	if want, got := "someone", m.Posts["anonymous.md"].Post.Metadata.Author; want != got {
+		t.Errorf("want default author, want= %v, got= %v", want, got)
+	}
+	if want, got := "mark", m.Posts["with_author.md"].Post.Metadata.Author; want != got {
+		t.Errorf("want author from metadata, want= %v, got= %v", want, got)
+	}
+	if index := string(mustRead(t, outDir+"/index.html")); !strings.Contains(index, "<title>Notebook</title>") {
+		t.Errorf("want index to use the site title, got:\n%s", index)
+	}
 }
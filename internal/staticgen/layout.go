@@ -0,0 +1,139 @@
+package staticgen
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/exklamationmark/notebook/internal/post"
+	"github.com/pkg/errors"
+)
+
+// Kinds of page, each rendered with the layout of the same name.
+const (
+	layoutPost    = "post"
+	layoutIndex   = "index"
+	layoutTag     = "tag"
+	layoutArchive = "archive"
+	layoutPage    = "page"
+)
+
+var layoutNames = []string{
+	layoutPost,
+	layoutIndex,
+	layoutTag,
+	layoutArchive,
+	layoutPage,
+}
+
+const (
+	layoutsDir  = "layouts"
+	partialsDir = "partials"
+)
+
+// page is the data every layout is executed with.
+// Only the fields relevant to its Kind are set.
+type page struct {
+	Kind  string
+	Title string
+	Post  *post.Post   // post, page
+	Posts []*post.Post // index, tag
+	Tag   string       // tag
+	Tags  []tagCount   // archive
+}
+
+type tagCount struct {
+	Name  string
+	Slug  string
+	Count int
+}
+
+// layouts are the templates in a template directory, organized as:
+//
+//	<dir>/layouts/<kind>.html  one per kind of page (post, index, tag, archive, page)
+//	<dir>/partials/*.html      {{define}}-ed blocks shared by every layout (header, nav, footer, ...)
+type layouts struct {
+	byName map[string]*template.Template
+	hash   string // changes whenever any layout or partial does
+	minify bool
+}
+
+func loadLayouts(dir string, funcs template.FuncMap) (*layouts, error) {
+	partials, err := filepath.Glob(filepath.Join(dir, partialsDir, "*.html"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list partials in %q", dir)
+	}
+	sort.Strings(partials)
+
+	var hashes bytes.Buffer
+	for _, fname := range partials {
+		h, err := hashFile(fname)
+		if err != nil {
+			return nil, err
+		}
+		hashes.WriteString(h)
+	}
+
+	l := &layouts{byName: make(map[string]*template.Template, len(layoutNames))}
+	for _, name := range layoutNames {
+		layoutFile := filepath.Join(dir, layoutsDir, name+".html")
+		if _, err := os.Stat(layoutFile); err != nil {
+			return nil, errors.Wrapf(err, "missing %q layout", name)
+		}
+
+		files := append([]string{layoutFile}, partials...)
+		tmpl, err := template.New(filepath.Base(layoutFile)).Funcs(funcs).ParseFiles(files...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse %q layout", name)
+		}
+		l.byName[name] = tmpl
+
+		h, err := hashFile(layoutFile)
+		if err != nil {
+			return nil, err
+		}
+		hashes.WriteString(h)
+	}
+	l.hash = hashBytes(hashes.Bytes())
+
+	return l, nil
+}
+
+func (l *layouts) get(name string) (*template.Template, error) {
+	tmpl, exist := l.byName[name]
+	if !exist {
+		return nil, errors.Errorf("unknown layout %q", name)
+	}
+
+	return tmpl, nil
+}
+
+// layoutForPost returns the name of the layout a post is rendered with:
+// the `layout:` from its metadata, or post by default.
+func layoutForPost(p *post.Post) string {
+	if len(p.Metadata.Layout) > 0 {
+		return p.Metadata.Layout
+	}
+
+	return layoutPost
+}
+
+func (l *layouts) render(fname string, tmpl *template.Template, data page) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return errors.Wrapf(err, "cannot execute %s template", data.Kind)
+	}
+
+	b := buf.Bytes()
+	if l.minify {
+		b = minifyHTML(b)
+	}
+	if err := ioutil.WriteFile(fname, b, 0664); err != nil {
+		return errors.Wrapf(err, "cannot write %q", fname)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package staticgen
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadLayouts(t *testing.T) {
+	l, err := loadLayouts("testdata/templates", funcMap(config{}))
+	if err != nil {
+		t.Fatalf("want loadLayouts() to return no error, got= %v", err)
+	}
+	for _, name := range layoutNames {
+		if _, err := l.get(name); err != nil {
+			t.Errorf("want %q layout to be loaded, err= %v", name, err)
+		}
+	}
+	if _, err := l.get("nope"); err == nil {
+		t.Errorf("want error for unknown layout, got none")
+	}
+	if len(l.hash) < 1 {
+		t.Errorf("want layouts to be hashed")
+	}
+}
+
+func TestLoadLayoutsMissingLayout(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+
+	copyDir(t, "testdata/templates", dir)
+	if err := os.Remove(dir + "/layouts/archive.html"); err != nil {
+		t.Fatalf("cannot remove layout, err= %v", err)
+	}
+
+	_, err = loadLayouts(dir, funcMap(config{}))
+	if err == nil || !strings.Contains(err.Error(), `missing "archive" layout`) {
+		t.Errorf("want missing layout error, got= %v", err)
+	}
+}
+
+func TestGenerateLayoutFromMetadata(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		layout      string
+		expectedErr string
+		expectedSub string
+	}{
+		{"default", "", "", "<article>"},
+		{"page", "page", "", `<article class="page">`},
+		{"unknown", "nope", `unknown layout "nope"`, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			postDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+			if err != nil {
+				t.Fatalf("cannot get temp dir %q, err= %v", postDir, err)
+			}
+			defer os.RemoveAll(postDir)
+			outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+			if err != nil {
+				t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+			}
+			defer os.RemoveAll(outDir)
+
+			content := "---\ntitle: about\nslug: about\npublished: 2018-01-01T00:00:00Z\nlayout: " + tc.layout + "\n---\nabout me\n"
+			if err := ioutil.WriteFile(postDir+"/about.md", []byte(content), 0664); err != nil {
+				t.Fatalf("cannot write post, err= %v", err)
+			}
+
+			err = Generate(postDir, "testdata/templates", outDir)
+			if len(tc.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Errorf("want error containing %q, got= %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want Generate() to return no error, got= %v", err)
+			}
+
+			b := mustRead(t, outDir+"/2018/01/01/about.html")
+			if !strings.Contains(string(b), tc.expectedSub) {
+				t.Errorf("want page to contain %q, got:\n%s", tc.expectedSub, b)
+			}
+			if !strings.Contains(string(b), "<title>about</title>") {
+				t.Errorf("want header partial to be rendered, got:\n%s", b)
+			}
+		})
+	}
+}
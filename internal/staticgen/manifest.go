@@ -0,0 +1,137 @@
+package staticgen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/exklamationmark/glog"
+	"github.com/exklamationmark/notebook/internal/post"
+	"github.com/pkg/errors"
+)
+
+const manifestFile = ".manifest.json"
+
+// manifest records what a build generated, so the next build can skip
+// unchanged posts and delete outputs that are no longer generated.
+type manifest struct {
+	BuildHash string                   `json:"build"`   // hash of the templates and config posts are rendered with
+	Posts     map[string]manifestEntry `json:"posts"`   // by source file, relative to the post directory
+	Outputs   []string                 `json:"outputs"` // every generated file, relative to the html directory
+}
+
+type manifestEntry struct {
+	Hash   string     `json:"hash"`
+	Output string     `json:"output"`
+	Post   *post.Post `json:"post"`
+}
+
+func newManifest(buildHash string) *manifest {
+	return &manifest{
+		BuildHash: buildHash,
+		Posts:     make(map[string]manifestEntry),
+	}
+}
+
+// loadManifest reads the manifest of the previous build. A missing or unreadable
+// manifest is not an error; everything is simply rebuilt.
+func loadManifest(htmlDir string) *manifest {
+	fname := htmlDir + "/" + manifestFile
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Warningf("cannot read build manifest %q, rebuilding everything, err= %v", fname, err)
+		}
+		return newManifest("")
+	}
+
+	m := newManifest("")
+	if err := json.Unmarshal(b, m); err != nil {
+		glog.Warningf("cannot decode build manifest %q, rebuilding everything, err= %v", fname, err)
+		return newManifest("")
+	}
+
+	return m
+}
+
+func (m *manifest) save(htmlDir string) error {
+	sort.Strings(m.Outputs)
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "cannot encode build manifest")
+	}
+
+	fname := htmlDir + "/" + manifestFile
+	if err := ioutil.WriteFile(fname, b, 0664); err != nil {
+		return errors.Wrapf(err, "cannot write build manifest %q", fname)
+	}
+
+	return nil
+}
+
+// reusable returns the post generated by the previous build from the same source,
+// if neither the source nor the build hash changed and its output still exists.
+func (m *manifest) reusable(prev *manifest, key, hash, htmlDir string) (*post.Post, bool) {
+	if m.BuildHash != prev.BuildHash {
+		return nil, false
+	}
+
+	entry, exist := prev.Posts[key]
+	if !exist || entry.Hash != hash || entry.Post == nil {
+		return nil, false
+	}
+	if _, err := os.Stat(htmlDir + "/" + entry.Output); err != nil {
+		return nil, false
+	}
+
+	return entry.Post, true
+}
+
+// removeStale deletes the outputs of the previous build that the current build
+// didn't generate, e.g pages of removed or renamed posts.
+func (m *manifest) removeStale(prev *manifest, htmlDir string) error {
+	current := make(map[string]struct{}, len(m.Outputs))
+	for _, out := range m.Outputs {
+		current[out] = struct{}{}
+	}
+
+	for _, out := range prev.Outputs {
+		if _, exist := current[out]; exist {
+			continue
+		}
+
+		fname := htmlDir + "/" + out
+		if err := os.Remove(fname); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "cannot remove stale output %q", fname)
+		}
+		glog.V(0).Infof("removed stale %s", fname)
+
+		// clean up directories (e.g 2018/07/21) left empty
+		for dir := filepath.Dir(fname); dir != htmlDir && len(dir) > len(htmlDir); dir = filepath.Dir(dir) {
+			if err := os.Remove(dir); err != nil {
+				break // not empty
+			}
+		}
+	}
+
+	return nil
+}
+
+func hashFile(fname string) (string, error) {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot read %q", fname)
+	}
+
+	return hashBytes(b), nil
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,163 @@
+package staticgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func copyFile(t *testing.T, src, dst string) {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatalf("cannot read %q, err= %v", src, err)
+	}
+	if err := ioutil.WriteFile(dst, b, 0664); err != nil {
+		t.Fatalf("cannot write %q, err= %v", dst, err)
+	}
+}
+
+func TestGenerateIncremental(t *testing.T) {
+	postDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", postDir, err)
+	}
+	defer os.RemoveAll(postDir)
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	tmplDir := postDir + "/templates"
+	copyDir(t, "testdata/templates", tmplDir)
+	copyFile(t, "testdata/test.md", postDir+"/test.md")
+	copyFile(t, "testdata/old_post.markdown", postDir+"/old_post.markdown")
+
+	newFile := outDir + "/2018/07/21/untitled.html"
+	oldFile := outDir + "/2016/01/01/untitled.html"
+	longAgo := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	modTime := func(fname string) time.Time {
+		stat, err := os.Stat(fname)
+		if err != nil {
+			t.Fatalf("cannot stat %q, err= %v", fname, err)
+		}
+		return stat.ModTime()
+	}
+	generate := func(opts ...opt) {
+		if err := Generate(postDir, tmplDir, outDir, opts...); err != nil {
+			t.Fatalf("want Generate() to return no error, got= %v", err)
+		}
+	}
+	resetModTimes := func() {
+		for _, fname := range []string{newFile, oldFile} {
+			if _, err := os.Stat(fname); err == nil {
+				os.Chtimes(fname, longAgo, longAgo)
+			}
+		}
+	}
+
+	generate()
+	resetModTimes()
+	if _, err := os.Stat(outDir + "/" + manifestFile); err != nil {
+		t.Fatalf("want build manifest to be written, err= %v", err)
+	}
+
+	// nothing changed
+	generate()
+	if modTime(newFile).After(longAgo) || modTime(oldFile).After(longAgo) {
+		t.Errorf("want unchanged posts to be skipped")
+	}
+
+	// site title changed
+	generate(Title("Other"))
+	if !modTime(newFile).After(longAgo) || !modTime(oldFile).After(longAgo) {
+		t.Errorf("want every post to be rebuilt when the site title changes")
+	}
+	generate()
+	resetModTimes()
+
+	// one post changed
+	if err := ioutil.WriteFile(postDir+"/test.md", append(mustRead(t, "testdata/test.md"), []byte("\nmore")...), 0664); err != nil {
+		t.Fatalf("cannot update post, err= %v", err)
+	}
+	generate()
+	if !modTime(newFile).After(longAgo) {
+		t.Errorf("want changed post to be re-rendered")
+	}
+	if modTime(oldFile).After(longAgo) {
+		t.Errorf("want unchanged post to be skipped")
+	}
+	resetModTimes()
+
+	// template changed
+	if err := ioutil.WriteFile(tmplDir+"/partials/footer.html", []byte(`{{define "footer"}}<footer>changed</footer>{{end}}`), 0664); err != nil {
+		t.Fatalf("cannot update template, err= %v", err)
+	}
+	generate()
+	if !modTime(newFile).After(longAgo) || !modTime(oldFile).After(longAgo) {
+		t.Errorf("want every post to be re-rendered after the template changed")
+	}
+	resetModTimes()
+
+	// post removed
+	if err := os.Remove(postDir + "/old_post.markdown"); err != nil {
+		t.Fatalf("cannot remove post, err= %v", err)
+	}
+	generate()
+	if _, err := os.Stat(oldFile); !os.IsNotExist(err) {
+		t.Errorf("want stale post to be removed, err= %v", err)
+	}
+	if _, err := os.Stat(outDir + "/2016"); !os.IsNotExist(err) {
+		t.Errorf("want empty directories to be removed, err= %v", err)
+	}
+	if _, err := os.Stat(newFile); err != nil {
+		t.Errorf("want remaining post to be kept, err= %v", err)
+	}
+}
+
+func TestLoadManifestCorrupted(t *testing.T) {
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	if err := ioutil.WriteFile(outDir+"/"+manifestFile, []byte("{not json"), 0664); err != nil {
+		t.Fatalf("cannot write manifest, err= %v", err)
+	}
+
+	m := loadManifest(outDir)
+	if len(m.BuildHash) > 0 || len(m.Posts) > 0 || len(m.Outputs) > 0 {
+		t.Errorf("want empty manifest, got= %#v", m)
+	}
+}
+
+func copyDir(t *testing.T, src, dst string) {
+	err := filepath.Walk(src, func(fname string, stat os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, fname)
+		if err != nil {
+			return err
+		}
+		if stat.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0776)
+		}
+		copyFile(t, fname, filepath.Join(dst, rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("cannot copy %q to %q, err= %v", src, dst, err)
+	}
+}
+
+func mustRead(t *testing.T, fname string) []byte {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("cannot read %q, err= %v", fname, err)
+	}
+	return b
+}
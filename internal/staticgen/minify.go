@@ -0,0 +1,220 @@
+package staticgen
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// The minifiers below are deliberately conservative: they remove comments and
+// whitespace, but never rename or rewrite code, so they can't break it.
+
+// minifyCSS removes comments and the whitespace around punctuation.
+func minifyCSS(src []byte) []byte {
+	var out bytes.Buffer
+	space := false // pending whitespace, written only if needed
+	for i := 0; i < len(src); i++ {
+		ch := src[i]
+		switch {
+		case ch == '"' || ch == '\'':
+			if space && !isCSSPunct(lastByte(&out)) {
+				out.WriteByte(' ')
+			}
+			space = false
+			end := skipString(src, i)
+			out.Write(src[i:end])
+			i = end - 1
+		case ch == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return out.Bytes()
+			}
+			i += 2 + end + 1
+			space = true
+		case isSpace(ch):
+			space = true
+		default:
+			if space && out.Len() > 0 && !isCSSPunct(lastByte(&out)) && !isCSSPunct(ch) {
+				out.WriteByte(' ')
+			}
+			space = false
+			if ch == '}' && lastByte(&out) == ';' {
+				out.Truncate(out.Len() - 1)
+			}
+			out.WriteByte(ch)
+		}
+	}
+
+	return out.Bytes()
+}
+
+// isCSSPunct returns true for the characters whitespace around can be removed.
+// ':' isn't one of them, as "a :hover" and "a:hover" are different selectors.
+func isCSSPunct(ch byte) bool {
+	return strings.IndexByte("{};,>", ch) >= 0
+}
+
+// minifyJS removes comments, indentation and empty lines. Line breaks are kept,
+// as automatic semicolon insertion depends on them.
+func minifyJS(src []byte) []byte {
+	var out bytes.Buffer
+	lineStart := true // skipping indentation
+	for i := 0; i < len(src); i++ {
+		ch := src[i]
+		switch {
+		case ch == '"' || ch == '\'' || ch == '`':
+			end := skipString(src, i)
+			out.Write(src[i:end])
+			i = end - 1
+		case ch == '/' && i+1 < len(src) && src[i+1] == '/':
+			end := bytes.IndexByte(src[i:], '\n')
+			if end < 0 {
+				i = len(src)
+				continue
+			}
+			i += end - 1
+			continue
+		case ch == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(src)
+				continue
+			}
+			i += 2 + end + 1
+			if !lineStart && !isSpace(lastByte(&out)) {
+				out.WriteByte(' ')
+			}
+			continue
+		case ch == '/' && startsRegexp(out.Bytes()):
+			end := skipRegexp(src, i)
+			out.Write(src[i:end])
+			i = end - 1
+		case ch == '\n' || ch == '\r':
+			trimTrailingSpace(&out)
+			if out.Len() > 0 && lastByte(&out) != '\n' {
+				out.WriteByte('\n')
+			}
+			lineStart = true
+			continue
+		case lineStart && isSpace(ch):
+			continue
+		default:
+			out.WriteByte(ch)
+		}
+		lineStart = false
+	}
+	trimTrailingSpace(&out)
+	if out.Len() > 0 && lastByte(&out) != '\n' {
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes()
+}
+
+func trimTrailingSpace(buf *bytes.Buffer) {
+	for buf.Len() > 0 && (lastByte(buf) == ' ' || lastByte(buf) == '\t') {
+		buf.Truncate(buf.Len() - 1)
+	}
+}
+
+// startsRegexp returns true if a '/' following code is a regexp literal rather than a division.
+func startsRegexp(code []byte) bool {
+	code = bytes.TrimRight(code, " \t\r\n")
+	if len(code) == 0 {
+		return true
+	}
+	if strings.IndexByte("(,=:[!&|?{};+-*%<>~^", code[len(code)-1]) >= 0 {
+		return true
+	}
+	for _, keyword := range []string{"return", "typeof", "case", "do", "else", "in", "of", "void", "delete", "throw", "new"} {
+		if bytes.HasSuffix(code, []byte(keyword)) {
+			before := len(code) - len(keyword) - 1
+			if before < 0 || !isIdent(code[before]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func skipRegexp(src []byte, start int) int {
+	inClass := false
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				return i + 1
+			}
+		case '\n':
+			return i // not a regexp after all, leave the rest alone
+		}
+	}
+
+	return len(src)
+}
+
+// skipString returns the index right after the string literal starting at start.
+func skipString(src []byte, start int) int {
+	quote := src[start]
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+
+	return len(src)
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f'
+}
+
+func isIdent(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+func lastByte(buf *bytes.Buffer) byte {
+	if buf.Len() == 0 {
+		return 0
+	}
+
+	return buf.Bytes()[buf.Len()-1]
+}
+
+var (
+	htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	// content of these elements is kept as-is
+	htmlPreserved = regexp.MustCompile(`(?is)<pre\b.*?</pre>|<textarea\b.*?</textarea>|<script\b.*?</script>|<style\b.*?</style>`)
+	htmlSpace     = regexp.MustCompile(`\s+`)
+)
+
+// minifyHTML removes comments and collapses whitespace, except inside
+// <pre>, <textarea>, <script> and <style>. Whitespace between tags becomes a
+// single space, not nothing, as it is significant between inline elements.
+func minifyHTML(src []byte) []byte {
+	var out bytes.Buffer
+	last := 0
+	for _, loc := range htmlPreserved.FindAllIndex(src, -1) {
+		out.Write(minifyHTMLText(src[last:loc[0]]))
+		out.Write(src[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	out.Write(minifyHTMLText(src[last:]))
+
+	return bytes.TrimSpace(out.Bytes())
+}
+
+func minifyHTMLText(text []byte) []byte {
+	text = htmlComment.ReplaceAll(text, nil)
+	return htmlSpace.ReplaceAll(text, []byte(" "))
+}
@@ -0,0 +1,74 @@
+package staticgen
+
+import "testing"
+
+func TestMinifyCSS(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"whitespace", "a {\n  color: red;\n  margin: 0 auto;\n}\n", "a{color: red;margin: 0 auto}"},
+		{"comments", "/* header */\na { b: c } /* trailing */", "a{b: c}"},
+		{"selectors", "a > b,\nc :hover {x: y}", "a>b,c :hover{x: y}"},
+		{"strings", `a { content: "  /* not a comment */  "; }`, `a{content: "  /* not a comment */  "}`},
+		{"media", "@media (max-width: 600px) {\n  a { b: c; }\n}", "@media (max-width: 600px){a{b: c}}"},
+		{"calc", "a { width: calc(100% - 2px); }", "a{width: calc(100% - 2px)}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if want, got := tc.expected, string(minifyCSS([]byte(tc.src))); want != got {
+				t.Errorf("wrong minified CSS,\n  want= %q\n   got= %q", want, got)
+			}
+		})
+	}
+}
+
+func TestMinifyJS(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"indentation", "function f() {\n\n    return 1;\n}\n", "function f() {\nreturn 1;\n}\n"},
+		{"line comment", "var a = 1; // one\n// nothing\nvar b = 2;", "var a = 1;\nvar b = 2;\n"},
+		{"block comment", "/**\n * doc\n */\nvar a = /* inline */ 1;", "var a =  1;\n"},
+		{"strings", "var s = \"// not a comment\", t = '/* nor */';", "var s = \"// not a comment\", t = '/* nor */';\n"},
+		{"template literal", "var s = `line\n    indented // kept`;", "var s = `line\n    indented // kept`;\n"},
+		{"regexp", "var re = /\\/\\/[a-z/]+/g; // comment", "var re = /\\/\\/[a-z/]+/g;\n"},
+		{"division", "var x = a / b; // comment", "var x = a / b;\n"},
+		{"return regexp", "return /a//.test(s)", "return /a//.test(s)\n"},
+		{"asi", "var a = 1\nvar b = 2\n", "var a = 1\nvar b = 2\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if want, got := tc.expected, string(minifyJS([]byte(tc.src))); want != got {
+				t.Errorf("wrong minified JS,\n  want= %q\n   got= %q", want, got)
+			}
+		})
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"whitespace", "<html>\n  <body>\n    <p>some   text</p>\n  </body>\n</html>\n", "<html> <body> <p>some text</p> </body> </html>"},
+		{"comments", "<p>a</p><!-- removed\n --><p>b</p>", "<p>a</p><p>b</p>"},
+		{"pre", "<div>\n  <pre><code>x  =\n  1</code></pre>\n</div>", "<div> <pre><code>x  =\n  1</code></pre> </div>"},
+		{"script", "<script>\n  var a = 1\n  var b = 2\n</script>", "<script>\n  var a = 1\n  var b = 2\n</script>"},
+		{"mixed preserved", "<pre> a </pre>\n\n<script> b </script>", "<pre> a </pre> <script> b </script>"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if want, got := tc.expected, string(minifyHTML([]byte(tc.src))); want != got {
+				t.Errorf("wrong minified HTML,\n  want= %q\n   got= %q", want, got)
+			}
+		})
+	}
+}
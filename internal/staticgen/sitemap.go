@@ -0,0 +1,136 @@
+package staticgen
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/exklamationmark/glog"
+	"github.com/exklamationmark/notebook/internal/post"
+	"github.com/pkg/errors"
+)
+
+const (
+	sitemapNS   = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sitemapFile = "sitemap.xml"
+	robotsFile  = "robots.txt"
+
+	defaultRobots = "User-agent: *\nDisallow:\n"
+)
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	NS      string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// generatedPages are top-level pages written by staticgen itself, as opposed
+// to hand-written pages (e.g about.html) that already live in the html directory.
+var generatedPages = map[string]struct{}{
+	"index.html":      {},
+	tagsDir + ".html": {},
+}
+
+func generateSitemap(outDir string, c config, posts []*post.Post) error {
+	set := urlSet{NS: sitemapNS}
+	add := func(path string, lastMod time.Time) {
+		u := sitemapURL{Loc: c.baseURL + "/" + path}
+		if !lastMod.IsZero() {
+			u.LastMod = lastMod.Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, u)
+	}
+
+	sorted := newestFirst(posts)
+	add("", lastUpdated(sorted))
+	for _, p := range sorted {
+		add(p.CanonicalPath(), p.LastModified())
+	}
+
+	tags, byTag := groupByTag(posts)
+	add(tagsDir, lastUpdated(posts))
+	for _, tag := range tags {
+		add(tagPath(tag), lastUpdated(byTag[tag]))
+	}
+
+	pages, err := staticPages(outDir)
+	if err != nil {
+		return err
+	}
+	aliases := make(map[string]struct{})
+	for _, p := range posts {
+		for _, a := range p.Metadata.Aliases {
+			aliases[a+".html"] = struct{}{}
+		}
+	}
+	for _, page := range pages {
+		if _, alias := aliases[page.Name()]; alias {
+			continue // page redirecting to a post, see AliasPages
+		}
+		add(strings.TrimSuffix(page.Name(), ".html"), page.ModTime().UTC())
+	}
+
+	fname := outDir + "/" + sitemapFile
+	if err := writeXML(fname, set); err != nil {
+		return errors.Wrapf(err, "cannot write sitemap")
+	}
+
+	glog.V(0).Infof("generated %s", fname)
+	return nil
+}
+
+// staticPages lists the hand-written top-level HTML pages in outDir.
+func staticPages(outDir string) ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(outDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list %q", outDir)
+	}
+
+	pages := make([]os.FileInfo, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".html" {
+			continue
+		}
+		if _, generated := generatedPages[info.Name()]; generated {
+			continue
+		}
+		pages = append(pages, info)
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].Name() < pages[j].Name()
+	})
+
+	return pages, nil
+}
+
+func generateRobots(outDir string, c config) error {
+	content := []byte(defaultRobots)
+	if len(c.robotsFile) > 0 {
+		b, err := ioutil.ReadFile(c.robotsFile)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read robots.txt template %q", c.robotsFile)
+		}
+		content = b
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	content = append(content, []byte("\nSitemap: "+c.baseURL+"/"+sitemapFile+"\n")...)
+
+	fname := outDir + "/" + robotsFile
+	if err := ioutil.WriteFile(fname, content, 0664); err != nil {
+		return errors.Wrapf(err, "cannot write %q", fname)
+	}
+
+	glog.V(0).Infof("generated %s", fname)
+	return nil
+}
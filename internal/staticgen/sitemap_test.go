@@ -0,0 +1,107 @@
+package staticgen
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateSitemap(t *testing.T) {
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	for _, fname := range []string{"index.html", "tags.html", "about.html", "old.html", "builtin.css"} {
+		if err := ioutil.WriteFile(outDir+"/"+fname, []byte("x"), 0664); err != nil {
+			t.Fatalf("cannot write %s, err= %v", fname, err)
+		}
+	}
+	aboutModTime := time.Date(2017, 5, 5, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(outDir+"/about.html", aboutModTime, aboutModTime); err != nil {
+		t.Fatalf("cannot change mtime, err= %v", err)
+	}
+
+	posts := feedPosts()
+	posts[0].Metadata.UpdatedAt = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	posts[0].Metadata.Aliases = []string{"old"} // old.html redirects to the post
+
+	c := config{baseURL: "https://example.com"}
+	if err := generateSitemap(outDir, c, posts); err != nil {
+		t.Fatalf("want generateSitemap() to return no error, got= %v", err)
+	}
+
+	b, err := ioutil.ReadFile(outDir + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("cannot read sitemap, err= %v", err)
+	}
+	var set urlSet
+	if err := xml.Unmarshal(b, &set); err != nil {
+		t.Fatalf("cannot decode sitemap, err= %v", err)
+	}
+
+	expected := []sitemapURL{
+		{Loc: "https://example.com/", LastMod: "2019-01-01T00:00:00Z"},
+		{Loc: "https://example.com/2018/01/01/new", LastMod: "2018-01-01T00:00:00Z"},
+		{Loc: "https://example.com/2016/01/01/old", LastMod: "2019-01-01T00:00:00Z"},
+		{Loc: "https://example.com/tags", LastMod: "2019-01-01T00:00:00Z"},
+		{Loc: "https://example.com/tags/go", LastMod: "2019-01-01T00:00:00Z"},
+		{Loc: "https://example.com/tags/terraform", LastMod: "2019-01-01T00:00:00Z"},
+		{Loc: "https://example.com/about", LastMod: "2017-05-05T00:00:00Z"},
+	}
+	if want, got := expected, set.URLs; !cmp.Equal(want, got) {
+		t.Errorf("wrong sitemap URLs\n  want= %v\n   got= %v\n  diff= %v", want, got, cmp.Diff(want, got))
+	}
+}
+
+func TestGenerateRobots(t *testing.T) {
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	custom := outDir + "/robots.tmpl"
+	if err := ioutil.WriteFile(custom, []byte("User-agent: *\nDisallow: /resume.html"), 0664); err != nil {
+		t.Fatalf("cannot write robots template, err= %v", err)
+	}
+
+	var testCases = []struct {
+		name       string
+		robotsFile string
+		expected   string
+	}{
+		{
+			"default",
+			"",
+			"User-agent: *\nDisallow:\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			"custom",
+			custom,
+			"User-agent: *\nDisallow: /resume.html\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := config{baseURL: "https://example.com", robotsFile: tc.robotsFile}
+			if err := generateRobots(outDir, c); err != nil {
+				t.Fatalf("want generateRobots() to return no error, got= %v", err)
+			}
+
+			b, err := ioutil.ReadFile(outDir + "/robots.txt")
+			if err != nil {
+				t.Fatalf("cannot read robots.txt, err= %v", err)
+			}
+			if want, got := tc.expected, string(b); want != got {
+				t.Errorf("wrong robots.txt\n  want= %q\n   got= %q", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package staticgen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/exklamationmark/glog"
+	"github.com/exklamationmark/notebook/internal/post"
+	"github.com/pkg/errors"
+)
+
+const tagsDir = "tags"
+
+// tagSymbols are spelled out in slugs, so that e.g "C++", "C#" and "C" don't share a page.
+var tagSymbols = strings.NewReplacer("+", "plus", "#", "sharp")
+
+// tagSlug turns a tag into something usable as a file name / URL segment,
+// e.g "programming, conference" => "programming-conference".
+func tagSlug(tag string) string {
+	var buf bytes.Buffer
+	dash := false
+	for _, r := range tagSymbols.Replace(strings.ToLower(strings.TrimSpace(tag))) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' {
+			buf.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && buf.Len() > 0 {
+			buf.WriteRune('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimRight(buf.String(), "-")
+}
+
+func tagPath(tag string) string {
+	return tagsDir + "/" + tagSlug(tag)
+}
+
+// groupByTag returns the tags (sorted) and the posts for each tag, newest first.
+// Spellings of a tag with the same slug (e.g "Go" and "go") share a page, so they
+// are grouped under one name, the first of them in sorted order.
+func groupByTag(posts []*post.Post) ([]string, map[string][]*post.Post) {
+	names := make(map[string]string) // by slug
+	bySlug := make(map[string][]*post.Post)
+	for _, p := range posts {
+		tagged := make(map[string]bool)
+		for _, tag := range p.Metadata.Tags {
+			slug := tagSlug(tag)
+			if len(slug) < 1 {
+				continue
+			}
+			if name, exist := names[slug]; !exist || tag < name {
+				names[slug] = tag
+			}
+			if !tagged[slug] {
+				tagged[slug] = true
+				bySlug[slug] = append(bySlug[slug], p)
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(bySlug))
+	byTag := make(map[string][]*post.Post, len(bySlug))
+	for slug, tagged := range bySlug {
+		sort.SliceStable(tagged, func(i, j int) bool {
+			first := tagged[i].Metadata.PublishedAt
+			second := tagged[j].Metadata.PublishedAt
+			return first.After(second)
+		})
+		tags = append(tags, names[slug])
+		byTag[names[slug]] = tagged
+	}
+	sort.Strings(tags)
+
+	return tags, byTag
+}
+
+func generateTags(outDir string, l *layouts, posts []*post.Post) error {
+	tags, byTag := groupByTag(posts)
+
+	tagDir := outDir + "/" + tagsDir
+	if err := os.MkdirAll(tagDir, 0776); err != nil {
+		return errors.Wrapf(err, "cannot create tag directory %q", tagDir)
+	}
+
+	tmpl, err := l.get(layoutTag)
+	if err != nil {
+		return err
+	}
+	counts := make([]tagCount, 0, len(tags))
+	for _, tag := range tags {
+		fname := fmt.Sprintf("%s/%s.html", outDir, tagPath(tag))
+		data := page{Kind: layoutTag, Title: "Tag: " + tag, Tag: tag, Posts: byTag[tag]}
+		if err := l.render(fname, tmpl, data); err != nil {
+			return errors.Wrapf(err, "cannot render tag %q", tag)
+		}
+		glog.V(0).Infof("generated %s", fname)
+
+		counts = append(counts, tagCount{Name: tag, Slug: tagSlug(tag), Count: len(byTag[tag])})
+	}
+
+	tmpl, err = l.get(layoutArchive)
+	if err != nil {
+		return err
+	}
+	fname := outDir + "/" + tagsDir + ".html"
+	data := page{Kind: layoutArchive, Title: "Tags", Tags: counts}
+	if err := l.render(fname, tmpl, data); err != nil {
+		return errors.Wrapf(err, "cannot render tag list")
+	}
+
+	glog.V(0).Infof("generated %s", fname)
+	return nil
+}
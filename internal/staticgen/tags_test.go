@@ -0,0 +1,123 @@
+package staticgen
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exklamationmark/notebook/internal/post"
+)
+
+func TestTagSlug(t *testing.T) {
+	var testCases = []struct {
+		tag      string
+		expected string
+	}{
+		{"terraform", "terraform"},
+		{"Go", "go"},
+		{"programming, conference", "programming-conference"},
+		{"  spaced  out  ", "spaced-out"},
+		{"c++", "cplusplus"},
+		{"C#", "csharp"},
+		{"C", "c"},
+		{"go1.10", "go1.10"},
+		{"!!!", ""},
+	}
+
+	for _, tc := range testCases {
+		if want, got := tc.expected, tagSlug(tc.tag); want != got {
+			t.Errorf("wrong slug for %q, want= %q, got= %q", tc.tag, want, got)
+		}
+	}
+}
+
+func TestGenerateTags(t *testing.T) {
+	l, err := loadLayouts("testdata/templates", funcMap(config{}))
+	if err != nil {
+		t.Fatalf("cannot load layouts, err= %v", err)
+	}
+	outDir, err := ioutil.TempDir(os.TempDir(), "staticgen")
+	if err != nil {
+		t.Fatalf("cannot get temp dir %q, err= %v", outDir, err)
+	}
+	defer os.RemoveAll(outDir)
+
+	newPost := func(title, slug string, publishedAt time.Time, tags ...string) *post.Post {
+		return &post.Post{
+			Metadata: post.Metadata{
+				Title:       title,
+				Slug:        slug,
+				PublishedAt: publishedAt,
+				Tags:        tags,
+			},
+		}
+	}
+	posts := []*post.Post{
+		newPost("Old", "old", time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), "go", "terraform"),
+		newPost("New", "new", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), "terraform"),
+	}
+
+	if err := generateTags(outDir, l, posts); err != nil {
+		t.Fatalf("want generateTags() to return no error, got= %v", err)
+	}
+
+	b, err := ioutil.ReadFile(outDir + "/tags/terraform.html")
+	if err != nil {
+		t.Fatalf("cannot read tag page, err= %v", err)
+	}
+	page := string(b)
+	newIdx := strings.Index(page, "/2018/01/01/new")
+	oldIdx := strings.Index(page, "/2016/01/01/old")
+	if newIdx < 0 || oldIdx < 0 || newIdx > oldIdx {
+		t.Errorf("want tag page to list posts newest-first, got:\n%s", page)
+	}
+
+	b, err = ioutil.ReadFile(outDir + "/tags.html")
+	if err != nil {
+		t.Fatalf("cannot read tag list, err= %v", err)
+	}
+	list := string(b)
+	for _, want := range []string{
+		`<a href="/tags/go">go</a> (1)`,
+		`<a href="/tags/terraform">terraform</a> (2)`,
+	} {
+		if !strings.Contains(list, want) {
+			t.Errorf("want tag list to contain %q, got:\n%s", want, list)
+		}
+	}
+}
+
+func TestGroupByTagSameSlug(t *testing.T) {
+	newPost := func(slug string, tags ...string) *post.Post {
+		return &post.Post{Metadata: post.Metadata{Slug: slug, Tags: tags}}
+	}
+	posts := []*post.Post{
+		newPost("first", "go", "C++"),
+		newPost("second", "Go", "c"),
+		newPost("third", "go", "Go"),
+	}
+
+	tags, byTag := groupByTag(posts)
+	if want, got := []string{"C++", "Go", "c"}, tags; !reflect.DeepEqual(want, got) {
+		t.Fatalf("wrong tags, want= %v, got= %v", want, got)
+	}
+	for tag, want := range map[string]int{"C++": 1, "Go": 3, "c": 1} {
+		if got := len(byTag[tag]); want != got {
+			t.Errorf("wrong number of posts for %q, want= %d, got= %d", tag, want, got)
+		}
+	}
+
+	pages := make(map[string]string)
+	for _, tag := range tags {
+		if other, exist := pages[tagPath(tag)]; exist {
+			t.Errorf("want a page per tag, got %q and %q on %s", other, tag, tagPath(tag))
+		}
+		pages[tagPath(tag)] = tag
+	}
+	if want, got := 3, len(pages); want != got {
+		t.Errorf("wrong number of tag pages, want= %d, got= %d (%v)", want, got, pages)
+	}
+}
@@ -0,0 +1 @@
+var x;
\ No newline at end of file